@@ -7,46 +7,62 @@ import (
 )
 
 type Animal struct {
+	ID           int // Unique within a world, stable across moves and rewinds
 	Position
 	Energy       int
 	ReproduceCD  int // Cooldown after reproduction
 	Age          int
+	Fatigue      float64 // Fractional energy spent but not yet taken from Energy
+	Digesting    int     // Ticks of digestion left after the last meal
+	Pregnant     int     // Ticks until giving birth, 0 when not pregnant
+	Litter       int     // Size of the litter being carried
+}
+
+// spendEnergy accumulates fractional energy costs and takes whole units off
+// the animal's energy once they add up.
+func spendEnergy(animal *Animal, cost float64) {
+	animal.Fatigue += cost
+	if animal.Fatigue >= 1 {
+		whole := int(animal.Fatigue)
+		animal.Energy -= whole
+		animal.Fatigue -= float64(whole)
+	}
 }
 
 type Rabbit struct {
 	Animal
 	NewBorn int // Ticks since birth (for visual indication)
+	Heading Position // Direction of the last move, used for herd alignment
 }
 
 type Fox struct {
 	Animal
+	PackID     int       // 1-based index into World.Packs, 0 when hunting alone
+	PackTarget *Position // Rabbit the pack is closing in on
+	Flank      Position  // Cell next to the target assigned to this fox
 }
 
 func (w *World) updateRabbits() {
 	for i := len(w.Rabbits) - 1; i >= 0; i-- {
 		rabbit := w.Rabbits[i]
 		
-		rabbit.Animal.Age++
-		if rabbit.Animal.ReproduceCD > 0 {
-			rabbit.Animal.ReproduceCD--
-		}
-		
-		if rabbit.NewBorn > 0 {
-			rabbit.NewBorn--
-		}
-		
-		if w.Tick%60 == 0 {
-			rabbit.Animal.Energy -= rabbitEnergyLoss
-		}
+		w.ageRabbit(rabbit)
 		
 		w.rabbitEatGrass(rabbit)
 		
-		if rand.Float64() < rabbitMoveChance {
+		action := activityIdle
+		if rand.Float64() < w.Params.RabbitMoveChance {
 			w.moveRabbit(rabbit)
 			w.rabbitEatGrass(rabbit)
+			action = activityMove
 		}
 		
+		w.metabolize(&rabbit.Animal, &w.RabbitParams, action, w.Params.RabbitEnergyLoss)
+		
+		w.depositScent(rabbit.Animal.Position)
+		
 		if rabbit.Animal.Energy <= 0 {
+			w.Events.RabbitsStarved++
 			w.removeRabbit(i)
 		}
 	}
@@ -54,16 +70,33 @@ func (w *World) updateRabbits() {
 	w.handleRabbitReproduction()
 }
 
+// ageRabbit does the per-tick bookkeeping that doesn't depend on what the
+// rabbit decides to do: ageing, cooldowns, births and crowding stress.
+func (w *World) ageRabbit(rabbit *Rabbit) {
+	rabbit.Animal.Age++
+	if rabbit.Animal.ReproduceCD > 0 {
+		rabbit.Animal.ReproduceCD--
+	}
+	
+	if rabbit.NewBorn > 0 {
+		rabbit.NewBorn--
+	}
+	
+	if advancePregnancy(&rabbit.Animal) {
+		w.birthRabbits(rabbit, rabbit.Animal.Litter)
+	}
+	
+	if w.Tick%60 == 0 {
+		rabbit.Animal.Energy -= w.crowdingEnergyCost(rabbit.Animal.Position, RabbitType)
+	}
+}
+
 func (w *World) rabbitEatGrass(rabbit *Rabbit) {
 	pos := rabbit.Animal.Position
 	grass, exists := w.Grass[pos]
 	
-	if exists && grass.Amount >= minGrassToEat {
-		rabbit.Animal.Energy += grassEnergyGain
-		
-		if rabbit.Animal.Energy > 100 {
-			rabbit.Animal.Energy = 100
-		}
+	if exists && grass.Amount >= w.Params.MinGrassToEat {
+		feed(&rabbit.Animal, &w.RabbitParams, w.Params.GrassEnergyGain)
 		
 		delete(w.Grass, pos)
 	}
@@ -72,6 +105,15 @@ func (w *World) rabbitEatGrass(rabbit *Rabbit) {
 func (w *World) moveRabbit(rabbit *Rabbit) {
 	w.Grid[rabbit.Animal.Position.X][rabbit.Animal.Position.Y] = Empty
 	
+	w.applyRabbitMove(rabbit, w.chooseRabbitMove(rabbit, sharedRand))
+	
+	w.Grid[rabbit.Animal.Position.X][rabbit.Animal.Position.Y] = RabbitType
+}
+
+// chooseRabbitMove decides where the rabbit goes next without changing the
+// world. It returns the current position when the rabbit is boxed in.
+// Randomness comes from rng so that decisions can be made in parallel.
+func (w *World) chooseRabbitMove(rabbit *Rabbit, rng *rand.Rand) Position {
 	moves := w.getAdjacentPositions(rabbit.Animal.Position)
 	
 	validMoves := make([]Position, 0)
@@ -82,23 +124,38 @@ func (w *World) moveRabbit(rabbit *Rabbit) {
 		}
 	}
 	
-	if len(validMoves) > 0 {
-		newPos := validMoves[rand.Intn(len(validMoves))]
-		rabbit.Animal.Position = newPos
+	if len(validMoves) == 0 {
+		return rabbit.Animal.Position
 	}
 	
-	w.Grid[rabbit.Animal.Position.X][rabbit.Animal.Position.Y] = RabbitType
+	if migratePos, ok := w.migrationMove(rabbit.Animal.Position, RabbitType, validMoves, rng); ok {
+		return migratePos
+	}
+	if w.herding {
+		return w.herdMove(rabbit, validMoves, rng)
+	}
+	return validMoves[rng.Intn(len(validMoves))]
+}
+
+func (w *World) applyRabbitMove(rabbit *Rabbit, newPos Position) {
+	if newPos == rabbit.Animal.Position {
+		return
+	}
+	
+	rabbit.Heading = Position{newPos.X - rabbit.Animal.Position.X, newPos.Y - rabbit.Animal.Position.Y}
+	rabbit.Animal.Position = newPos
+	w.chargeMoveCost(&rabbit.Animal)
 }
 
 func (w *World) handleRabbitReproduction() {
 	processedPairs := make(map[string]bool)
 	
 	for _, rabbit := range w.Rabbits {
-		if rabbit.Animal.Energy < reproduceEnergyThreshold || rabbit.Animal.ReproduceCD > 0 {
+		if rabbit.Animal.Energy < w.Params.ReproduceEnergyThreshold || rabbit.Animal.ReproduceCD > 0 {
 			continue
 		}
 		
-		if rand.Float64() >= reproduceChance {
+		if rand.Float64() >= w.Params.ReproduceChance*w.reproductionFactor(rabbit.Animal.Position, RabbitType) {
 			continue
 		}
 		
@@ -121,7 +178,7 @@ func (w *World) findAdjacentReproductivePartner(rabbit *Rabbit, processedPairs m
 		if w.Grid[pos.X][pos.Y] == RabbitType {
 			partner := w.findRabbitAtPosition(pos)
 			if partner != nil && 
-			   partner.Animal.Energy >= reproduceEnergyThreshold && 
+			   partner.Animal.Energy >= w.Params.ReproduceEnergyThreshold && 
 			   partner.Animal.ReproduceCD == 0 {
 				
 				pairKey := fmt.Sprintf("%d,%d-%d,%d", rabbit.Animal.Position.X, rabbit.Animal.Position.Y, partner.Animal.Position.X, partner.Animal.Position.Y)
@@ -143,38 +200,37 @@ func (w *World) findRabbitAtPosition(pos Position) *Rabbit {
 	return nil
 }
 
+func (w *World) findRabbitByID(id int) *Rabbit {
+	for _, rabbit := range w.Rabbits {
+		if rabbit.Animal.ID == id {
+			return rabbit
+		}
+	}
+	return nil
+}
+
 func (w *World) createBabyRabbit(parent1, parent2 *Rabbit) {
-	if len(w.Rabbits) >= maxRabbits {
+	if len(w.Rabbits) >= w.rabbitLimit() {
 		return
 	}
 	
-	adjacentPositions := w.getAdjacentPositions(parent1.Animal.Position)
-	
-	for _, pos := range adjacentPositions {
-		if w.Grid[pos.X][pos.Y] == Empty {
-			baby := &Rabbit{
-				Animal: Animal{
-					Position:    pos,
-					Energy:      60,
-					ReproduceCD: reproductionCooldown,
-					Age:         0,
-				},
-				NewBorn: 180, // 30 seconds
-			}
-			
-			w.Rabbits = append(w.Rabbits, baby)
-			w.Grid[pos.X][pos.Y] = RabbitType
-			
-			parent1.Animal.Energy -= 20
-			parent2.Animal.Energy -= 20
-			parent1.Animal.ReproduceCD = reproductionCooldown
-			parent2.Animal.ReproduceCD = reproductionCooldown
-			
-			log.Printf("New rabbit born at (%d,%d)! Total rabbits: %d", pos.X, pos.Y, len(w.Rabbits))
-			
+	if w.gestation {
+		mother := parent1
+		if mother.Animal.Pregnant > 0 {
+			mother = parent2
+		}
+		if !conceive(&mother.Animal, &w.RabbitParams) {
 			return
 		}
+		log.Printf("Rabbit at (%d,%d) is expecting %d baby(ies)", mother.Animal.Position.X, mother.Animal.Position.Y, mother.Animal.Litter)
+	} else if w.birthRabbits(parent1, 1) == 0 {
+		return
 	}
+	
+	spendEnergy(&parent1.Animal, w.RabbitParams.ReproduceCost)
+	spendEnergy(&parent2.Animal, w.RabbitParams.ReproduceCost)
+	parent1.Animal.ReproduceCD = w.Params.ReproductionCooldown
+	parent2.Animal.ReproduceCD = w.Params.ReproductionCooldown
 }
 
 func (w *World) removeRabbit(index int) {
@@ -186,53 +242,90 @@ func (w *World) removeRabbit(index int) {
 }
 
 func (w *World) updateFoxes() {
+	w.formPacks()
+	
 	for i := len(w.Foxes) - 1; i >= 0; i-- {
 		fox := w.Foxes[i]
 		
-		fox.Animal.Age++
-		if fox.Animal.ReproduceCD > 0 {
-			fox.Animal.ReproduceCD--
-		}
-		
-		// Lose energy only every 60 ticks
-		if w.Tick%60 == 0 {
-			fox.Animal.Energy -= foxEnergyLoss
-		}
+		w.ageFox(fox)
 		
 		w.foxHuntRabbit(fox)
 		
-		if rand.Float64() < foxMoveChance {
+		action := activityIdle
+		if rand.Float64() < w.Params.FoxMoveChance {
 			if w.smartHunting {
-				w.moveFoxSmart(fox)
+				action = w.moveFoxSmart(fox)
 			} else {
-				w.moveFoxHunting(fox)
+				action = w.moveFoxHunting(fox)
 			}
 			w.foxHuntRabbit(fox)
 		}
 		
-		if fox.Animal.Energy >= foxReproduceThreshold && fox.Animal.ReproduceCD == 0 {
-			if rand.Float64() < reproduceChance*1.5 {
-				w.tryFoxReproduction(fox)
-			}
-		}
+		w.metabolize(&fox.Animal, &w.FoxParams, action, w.Params.FoxEnergyLoss)
+		
+		w.foxReproduction(fox)
 		
 		if fox.Animal.Energy <= 0 {
+			w.Events.FoxesStarved++
 			w.removeFox(i)
 		}
 	}
 }
 
-func (w *World) moveFoxSmart(fox *Fox) {
+func (w *World) ageFox(fox *Fox) {
+	fox.Animal.Age++
+	if fox.Animal.ReproduceCD > 0 {
+		fox.Animal.ReproduceCD--
+	}
+	
+	if advancePregnancy(&fox.Animal) {
+		w.birthFoxes(fox, fox.Animal.Litter)
+	}
+	
+	if w.Tick%60 == 0 {
+		fox.Animal.Energy -= w.crowdingEnergyCost(fox.Animal.Position, FoxType)
+	}
+}
+
+func (w *World) foxReproduction(fox *Fox) {
+	if fox.Animal.Energy >= w.Params.FoxReproduceThreshold && fox.Animal.ReproduceCD == 0 {
+		if rand.Float64() < w.Params.ReproduceChance*1.5*w.reproductionFactor(fox.Animal.Position, FoxType) {
+			w.tryFoxReproduction(fox)
+		}
+	}
+}
+
+// moveFoxSmart moves the fox and reports whether it was chasing prey or just
+// wandering, for the metabolism.
+func (w *World) moveFoxSmart(fox *Fox) activity {
 	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = Empty
 	
+	newPos, action := w.chooseFoxSmartMove(fox, sharedRand)
+	action = w.applyFoxMove(fox, newPos, action)
+	
+	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = FoxType
+	
+	return action
+}
+
+// chooseFoxSmartMove decides the next cell for a fox that can see
+// Params.FoxVisionRange cells: its pack's plan first, then a visible rabbit, then a
+// scent trail, and a random (or migrating) step when there is nothing to hunt.
+func (w *World) chooseFoxSmartMove(fox *Fox, rng *rand.Rand) (Position, activity) {
 	targetRabbit := w.findNearestRabbit(fox.Animal.Position)
 	
+	action := activityChase
 	var newPos Position
-	if targetRabbit != nil {
+	if fox.PackTarget != nil {
+		newPos = w.packMove(fox)
+	} else if targetRabbit != nil {
 		newPos = w.moveTowardsTarget(fox.Animal.Position, *targetRabbit)
 		log.Printf("Fox at (%d,%d) spotted rabbit at (%d,%d), moving towards it", 
 			fox.Animal.Position.X, fox.Animal.Position.Y, targetRabbit.X, targetRabbit.Y)
+	} else if scentPos, ok := w.followScent(fox.Animal.Position, rng); ok {
+		newPos = scentPos
 	} else {
+		action = activityMove
 		moves := w.getAdjacentPositions(fox.Animal.Position)
 		validMoves := make([]Position, 0)
 		for _, pos := range moves {
@@ -242,23 +335,36 @@ func (w *World) moveFoxSmart(fox *Fox) {
 			}
 		}
 		
-		if len(validMoves) > 0 {
-			newPos = validMoves[rand.Intn(len(validMoves))]
+		if migratePos, ok := w.migrationMove(fox.Animal.Position, FoxType, validMoves, rng); ok {
+			newPos = migratePos
+		} else if len(validMoves) > 0 {
+			newPos = validMoves[rng.Intn(len(validMoves))]
 		} else {
 			newPos = fox.Animal.Position
 		}
 	}
 	
+	return newPos, action
+}
+
+// applyFoxMove moves the fox to newPos and returns the activity to bill it for;
+// a fox that stays where it is only pays for idling.
+func (w *World) applyFoxMove(fox *Fox, newPos Position, action activity) activity {
+	if newPos == fox.Animal.Position {
+		return activityIdle
+	}
+	
 	fox.Animal.Position = newPos
-	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = FoxType
+	w.chargeMoveCost(&fox.Animal)
+	return action
 }
 
 func (w *World) findNearestRabbit(foxPos Position) *Position {
 	var nearestRabbit *Position
-	minDistance := foxVisionRange + 1
+	minDistance := w.Params.FoxVisionRange + 1
 	
-	for dx := -foxVisionRange; dx <= foxVisionRange; dx++ {
-		for dy := -foxVisionRange; dy <= foxVisionRange; dy++ {
+	for dx := -w.Params.FoxVisionRange; dx <= w.Params.FoxVisionRange; dx++ {
+		for dy := -w.Params.FoxVisionRange; dy <= w.Params.FoxVisionRange; dy++ {
 			if dx == 0 && dy == 0 {
 				continue
 			}
@@ -266,7 +372,7 @@ func (w *World) findNearestRabbit(foxPos Position) *Position {
 			x := foxPos.X + dx
 			y := foxPos.Y + dy
 			
-			if x < 0 || x >= gridWidth || y < 0 || y >= gridHeight {
+			if x < 0 || x >= w.Width || y < 0 || y >= w.Height {
 				continue
 			}
 			
@@ -311,9 +417,20 @@ func abs(x int) int {
 	return x
 }
 
-func (w *World) moveFoxHunting(fox *Fox) {
+func (w *World) moveFoxHunting(fox *Fox) activity {
 	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = Empty
 	
+	newPos, action := w.chooseFoxHuntingMove(fox, sharedRand)
+	action = w.applyFoxMove(fox, newPos, action)
+	
+	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = FoxType
+	
+	return action
+}
+
+// chooseFoxHuntingMove decides the next cell for a fox with basic vision:
+// it pounces on an adjacent rabbit if there is one and wanders otherwise.
+func (w *World) chooseFoxHuntingMove(fox *Fox, rng *rand.Rand) (Position, activity) {
 	moves := w.getAdjacentPositions(fox.Animal.Position)
 	
 	rabbitMoves := make([]Position, 0)
@@ -330,14 +447,15 @@ func (w *World) moveFoxHunting(fox *Fox) {
 	
 	// Prefer moving to rabbit positions (hunting!)
 	if len(rabbitMoves) > 0 {
-		newPos := rabbitMoves[rand.Intn(len(rabbitMoves))]
-		fox.Animal.Position = newPos
-	} else if len(validMoves) > 0 {
-		newPos := validMoves[rand.Intn(len(validMoves))]
-		fox.Animal.Position = newPos
+		return rabbitMoves[rng.Intn(len(rabbitMoves))], activityChase
 	}
-	
-	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = FoxType
+	if migratePos, ok := w.migrationMove(fox.Animal.Position, FoxType, validMoves, rng); ok {
+		return migratePos, activityMove
+	}
+	if len(validMoves) > 0 {
+		return validMoves[rng.Intn(len(validMoves))], activityMove
+	}
+	return fox.Animal.Position, activityIdle
 }
 
 func (w *World) foxHuntRabbit(fox *Fox) {
@@ -345,13 +463,16 @@ func (w *World) foxHuntRabbit(fox *Fox) {
 	
 	for i, rabbit := range w.Rabbits {
 		if rabbit.Animal.Position.X == pos.X && rabbit.Animal.Position.Y == pos.Y {
-			fox.Animal.Energy += rabbitEnergyGain
-			
-			if fox.Animal.Energy > 150 {
-				fox.Animal.Energy = 150
+			if !w.attemptCatch(fox, rabbit) {
+				return
 			}
 			
+			feed(&fox.Animal, &w.FoxParams, w.shareKill(fox, w.Params.RabbitEnergyGain))
+			
+			rabbit.Animal.Energy = 0
+			w.Events.RabbitsEaten++
 			w.removeRabbit(i)
+			w.Grid[pos.X][pos.Y] = FoxType
 			
 			log.Printf("Fox hunted rabbit at (%d,%d)! Rabbits left: %d", pos.X, pos.Y, len(w.Rabbits))
 			return
@@ -375,13 +496,14 @@ func (w *World) moveFox(fox *Fox) {
 	if len(validMoves) > 0 {
 		newPos := validMoves[rand.Intn(len(validMoves))]
 		fox.Animal.Position = newPos
+		w.chargeMoveCost(&fox.Animal)
 	}
 	
 	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = FoxType
 }
 
 func (w *World) tryFoxReproduction(fox *Fox) {
-	if len(w.Foxes) >= maxFoxes {
+	if len(w.Foxes) >= w.foxLimit() {
 		return
 	}
 	
@@ -391,32 +513,27 @@ func (w *World) tryFoxReproduction(fox *Fox) {
 		if w.Grid[pos.X][pos.Y] == FoxType {
 			partner := w.findFoxAtPosition(pos)
 			if partner != nil && 
-			   partner.Animal.Energy >= foxReproduceThreshold && 
+			   partner.Animal.Energy >= w.Params.FoxReproduceThreshold && 
 			   partner.Animal.ReproduceCD == 0 {
 				
-				for _, babyPos := range w.getAdjacentPositions(fox.Animal.Position) {
-					if w.Grid[babyPos.X][babyPos.Y] == Empty {
-						baby := &Fox{
-							Animal: Animal{
-								Position:    babyPos,
-								Energy:      60,
-								ReproduceCD: reproductionCooldown,
-								Age:         0,
-							},
-						}
-						
-						w.Foxes = append(w.Foxes, baby)
-						w.Grid[babyPos.X][babyPos.Y] = FoxType
-						
-						fox.Animal.Energy -= 30
-						partner.Animal.Energy -= 30
-						fox.Animal.ReproduceCD = reproductionCooldown
-						partner.Animal.ReproduceCD = reproductionCooldown
-						
-						log.Printf("New fox born at (%d,%d)! Total foxes: %d", babyPos.X, babyPos.Y, len(w.Foxes))
-						return
+				if w.gestation {
+					mother := fox
+					if mother.Animal.Pregnant > 0 {
+						mother = partner
 					}
+					if !conceive(&mother.Animal, &w.FoxParams) {
+						continue
+					}
+					log.Printf("Fox at (%d,%d) is expecting %d cub(s)", mother.Animal.Position.X, mother.Animal.Position.Y, mother.Animal.Litter)
+				} else if w.birthFoxes(fox, 1) == 0 {
+					return
 				}
+				
+				spendEnergy(&fox.Animal, w.FoxParams.ReproduceCost)
+				spendEnergy(&partner.Animal, w.FoxParams.ReproduceCost)
+				fox.Animal.ReproduceCD = w.Params.ReproductionCooldown
+				partner.Animal.ReproduceCD = w.Params.ReproductionCooldown
+				return
 			}
 		}
 	}
@@ -431,6 +548,15 @@ func (w *World) findFoxAtPosition(pos Position) *Fox {
 	return nil
 }
 
+func (w *World) findFoxByID(id int) *Fox {
+	for _, fox := range w.Foxes {
+		if fox.Animal.ID == id {
+			return fox
+		}
+	}
+	return nil
+}
+
 func (w *World) removeFox(index int) {
 	fox := w.Foxes[index]
 	
@@ -438,5 +564,16 @@ func (w *World) removeFox(index int) {
 	
 	w.Grid[fox.Animal.Position.X][fox.Animal.Position.Y] = Empty
 	
+	if fox.PackID > 0 {
+		pack := w.Packs[fox.PackID-1]
+		for i, member := range pack {
+			if member == fox {
+				w.Packs[fox.PackID-1] = append(pack[:i], pack[i+1:]...)
+				break
+			}
+		}
+		fox.PackID = 0
+	}
+	
 	w.Foxes = append(w.Foxes[:index], w.Foxes[index+1:]...)
 }
\ No newline at end of file
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// Animated export of the run: the world grid and the population chart at
+// every recorded sample, written as an animated GIF or APNG. The world is
+// captured while the simulation runs, at most maxHistoryPoints frames spread
+// evenly over the run: when the limit is reached every other frame is dropped
+// and frames are taken half as often.
+
+// animationOptions are set with -anim, -anim-fps and -anim-width. The frame
+// rate is at most maxAnimationFPS: GIF delays are whole hundredths of a second
+// and browsers slow down delays shorter than two.
+type animationOptions struct {
+	format *animationFormat
+	fps    int
+	width  int // Height follows from the screen's aspect ratio
+}
+
+func (o animationOptions) height() int {
+	return o.width * screenHeight / screenWidth
+}
+
+type animationFormat struct {
+	name string
+	ext  string
+	open func(w io.Writer, frames int, options animationOptions) animationEncoder
+}
+
+var animationFormats = []animationFormat{
+	{"gif", "gif", newGIFEncoder},
+	{"apng", "png", newAPNGEncoder},
+}
+
+func parseAnimationFormat(name string) (*animationFormat, error) {
+	name = strings.TrimSpace(strings.ToLower(name))
+	for i := range animationFormats {
+		if animationFormats[i].name == name {
+			return &animationFormats[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown animation format %q", name)
+}
+
+func animationFormatNames() string {
+	names := make([]string, len(animationFormats))
+	for i, f := range animationFormats {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// animationEncoder writes the frames of one animation in order. All frames
+// have the size given by the options.
+type animationEncoder interface {
+	addFrame(frame *image.RGBA) error
+	close() error
+}
+
+// worldFrame is the world grid at one sample, one pixel per cell or fewer
+// for grids larger than the animation.
+type worldFrame struct {
+	tick       int
+	sample     int // Index of the sample in the population history
+	gridWidth  int
+	gridHeight int
+	cells      *image.Paletted
+}
+
+type worldRecorder struct {
+	frames  []worldFrame
+	every   int // Samples between captured frames
+	samples int // Samples seen so far
+	
+	maxWidth, maxHeight int // Largest captured image
+}
+
+func newWorldRecorder(options animationOptions) *worldRecorder {
+	return &worldRecorder{
+		every:     1,
+		maxWidth:  options.width,
+		maxHeight: options.height() * gameAreaHeight / screenHeight,
+	}
+}
+
+// sample is called for every population sample and captures the world when
+// a frame is due.
+func (r *worldRecorder) sample(w *World) {
+	index := r.samples
+	r.samples++
+	if index%r.every != 0 {
+		return
+	}
+	
+	r.frames = append(r.frames, worldFrame{
+		tick:       w.Tick,
+		sample:     index,
+		gridWidth:  w.Width,
+		gridHeight: w.Height,
+		cells:      captureWorld(w, r.maxWidth, r.maxHeight),
+	})
+	
+	if len(r.frames) > maxHistoryPoints {
+		r.every *= 2
+		kept := r.frames[:0]
+		for _, frame := range r.frames {
+			if frame.sample%r.every == 0 {
+				kept = append(kept, frame)
+			}
+		}
+		r.frames = kept
+	}
+}
+
+// truncateAfter drops the frames captured after the given tick, when the
+// population history was cut back to the given number of samples.
+func (r *worldRecorder) truncateAfter(tick, samples int) {
+	for len(r.frames) > 0 && r.frames[len(r.frames)-1].tick > tick {
+		r.frames = r.frames[:len(r.frames)-1]
+	}
+	r.samples = samples
+}
+
+const grassShades = 16
+
+// worldPalette has black, the grass shades, rabbits, newborn rabbits, foxes
+// and then the region colours.
+func worldPalette(w *World) color.Palette {
+	colors := color.Palette{color.RGBA{0, 0, 0, 255}}
+	for shade := 0; shade < grassShades; shade++ {
+		colors = append(colors, grassColor(shade*maxGrassAmount/(grassShades-1)))
+	}
+	colors = append(colors, rabbitColor(nil), rabbitColor(&Rabbit{NewBorn: 1}), foxColor)
+	for _, region := range w.Regions {
+		colors = append(colors, region.Color)
+	}
+	return colors
+}
+
+// captureWorld draws the grid with the same colours as drawWorld, scaled
+// down to fit maxWidth x maxHeight. Animals are drawn over whatever else
+// falls in their pixel so none are lost when cells are merged.
+func captureWorld(w *World, maxWidth, maxHeight int) *image.Paletted {
+	width, height := w.Width, w.Height
+	if width > maxWidth || height > maxHeight {
+		scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+		width, height = max(int(float64(width)*scale), 1), max(int(float64(height)*scale), 1)
+	}
+	
+	const (
+		grassIndex   = 1
+		rabbitIndex  = grassIndex + grassShades
+		newbornIndex = rabbitIndex + 1
+		foxIndex     = rabbitIndex + 2
+		regionIndex  = rabbitIndex + 3
+	)
+	
+	img := image.NewPaletted(image.Rect(0, 0, width, height), worldPalette(w))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pos := Position{x * w.Width / width, y * w.Height / height}
+			index := uint8(0)
+			if w.regionsEnabled {
+				index = uint8(regionIndex + w.RegionMap[pos.X][pos.Y])
+			}
+			if grass, ok := w.Grass[pos]; ok {
+				index = uint8(grassIndex + grass.Amount*(grassShades-1)/maxGrassAmount)
+			}
+			img.Pix[img.PixOffset(x, y)] = index
+		}
+	}
+	
+	for _, rabbit := range w.Rabbits {
+		pos := rabbit.Animal.Position
+		index := uint8(rabbitIndex)
+		if rabbit.NewBorn > 0 {
+			index = newbornIndex
+		}
+		img.Pix[img.PixOffset(pos.X*width/w.Width, pos.Y*height/w.Height)] = index
+	}
+	for _, fox := range w.Foxes {
+		pos := fox.Animal.Position
+		img.Pix[img.PixOffset(pos.X*width/w.Width, pos.Y*height/w.Height)] = foxIndex
+	}
+	return img
+}
+
+// scaleImage resizes an image by averaging the source pixels that fall into
+// each destination pixel.
+func scaleImage(src *image.RGBA, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+	
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					offset := src.PixOffset(sx, sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
+}
+
+// gifEncoder maps every frame to the Plan 9 palette and writes the whole
+// animation when closed. Delays are whole hundredths of a second, so each
+// frame ends at the hundredth nearest to its exact time: at 30 fps the delays
+// go 3, 4, 3, ... and the animation keeps the right length.
+type gifEncoder struct {
+	w    io.Writer
+	fps  int
+	anim gif.GIF
+}
+
+func newGIFEncoder(w io.Writer, frames int, options animationOptions) animationEncoder {
+	return &gifEncoder{w: w, fps: options.fps}
+}
+
+func (e *gifEncoder) addFrame(frame *image.RGBA) error {
+	paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+	draw.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min, draw.Src)
+	e.anim.Image = append(e.anim.Image, paletted)
+	
+	n := len(e.anim.Image)
+	end := func(frames int) int { return (200*frames + e.fps) / (2 * e.fps) } // Rounded 100*frames/fps
+	e.anim.Delay = append(e.anim.Delay, end(n)-end(n-1))
+	return nil
+}
+
+func (e *gifEncoder) close() error {
+	return gif.EncodeAll(e.w, &e.anim)
+}
+
+// apngEncoder writes an animated PNG frame by frame. Each frame is encoded
+// with image/png and its image data moved into the animation chunks: the
+// first frame's IDAT chunks are kept so viewers without APNG support show
+// it, later frames go into fdAT chunks.
+type apngEncoder struct {
+	w        io.Writer
+	frames   int
+	fps      int
+	written  int
+	sequence uint32
+	header   []byte // IHDR of the first frame; every frame must match it
+	err      error
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func newAPNGEncoder(w io.Writer, frames int, options animationOptions) animationEncoder {
+	return &apngEncoder{w: w, frames: frames, fps: options.fps}
+}
+
+func (e *apngEncoder) writeChunk(kind string, data []byte) {
+	if e.err != nil {
+		return
+	}
+	
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], kind)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+	for _, part := range [][]byte{header[:], data, footer[:]} {
+		if _, err := e.w.Write(part); err != nil {
+			e.err = err
+			return
+		}
+	}
+}
+
+func (e *apngEncoder) addFrame(frame *image.RGBA) error {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, frame); err != nil {
+		return err
+	}
+	
+	data := encoded.Bytes()[len(pngSignature):]
+	first := e.written == 0
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data[:4])
+		kind := string(data[4:8])
+		body := data[8 : 8+length]
+		data = data[12+length:]
+		
+		switch kind {
+		case "IHDR":
+			if first {
+				e.header = body
+				if _, err := e.w.Write(pngSignature); err != nil {
+					return err
+				}
+				e.writeChunk("IHDR", body)
+				
+				control := make([]byte, 8)
+				binary.BigEndian.PutUint32(control[:4], uint32(e.frames))
+				e.writeChunk("acTL", control) // Plays forever
+			} else if !bytes.Equal(body, e.header) {
+				return fmt.Errorf("animation frame %d differs in size or colour type", e.written)
+			}
+			e.writeFrameControl(frame.Bounds())
+		case "IDAT":
+			if first {
+				e.writeChunk("IDAT", body)
+				continue
+			}
+			chunk := make([]byte, 4+len(body))
+			binary.BigEndian.PutUint32(chunk[:4], e.sequence)
+			copy(chunk[4:], body)
+			e.sequence++
+			e.writeChunk("fdAT", chunk)
+		}
+	}
+	
+	e.written++
+	return e.err
+}
+
+func (e *apngEncoder) writeFrameControl(bounds image.Rectangle) {
+	control := make([]byte, 26)
+	binary.BigEndian.PutUint32(control[0:], e.sequence)
+	binary.BigEndian.PutUint32(control[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(control[8:], uint32(bounds.Dy()))
+	binary.BigEndian.PutUint16(control[20:], 1) // Delay of 1/fps seconds
+	binary.BigEndian.PutUint16(control[22:], uint16(e.fps))
+	e.sequence++
+	e.writeChunk("fcTL", control)
+}
+
+func (e *apngEncoder) close() error {
+	if e.written != e.frames {
+		return fmt.Errorf("animation has %d frames, %d announced", e.written, e.frames)
+	}
+	e.writeChunk("IEND", nil)
+	return e.err
+}
+
+// writeAnimation renders count frames with render and writes them to
+// filename in the chosen format.
+func writeAnimation(filename string, count int, options animationOptions, render func(i int) *image.RGBA) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	
+	encoder := options.format.open(writer, count, options)
+	for i := 0; i < count && err == nil; i++ {
+		err = encoder.addFrame(scaleImage(render(i), options.width, options.height()))
+	}
+	if err == nil {
+		err = encoder.close()
+	}
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+var frameColors = []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}}
+
+func solidFrame(c color.RGBA, width, height int) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(frame.Pix); i += 4 {
+		frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return frame
+}
+
+func encodeFrames(t *testing.T, format string, fps, count int) []byte {
+	t.Helper()
+	animFormat, err := parseAnimationFormat(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	encoder := animFormat.open(&buf, count, animationOptions{format: animFormat, fps: fps, width: 40})
+	for i := 0; i < count; i++ {
+		if err := encoder.addFrame(solidFrame(frameColors[i%len(frameColors)], 40, 30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func TestGIFEncoder(t *testing.T) {
+	tests := []struct {
+		fps, frames int
+		total       int // Hundredths of a second
+	}{
+		{1, 3, 300},
+		{10, 5, 50},
+		{30, 9, 30},
+		{30, 10, 33},
+		{50, 7, 14},
+		{7, 7, 100},
+	}
+	
+	for _, tt := range tests {
+		anim, err := gif.DecodeAll(bytes.NewReader(encodeFrames(t, "gif", tt.fps, tt.frames)))
+		if err != nil {
+			t.Fatalf("%d fps: %v", tt.fps, err)
+		}
+		if len(anim.Image) != tt.frames {
+			t.Fatalf("%d fps: %d frames, want %d", tt.fps, len(anim.Image), tt.frames)
+		}
+		
+		total := 0
+		for i, delay := range anim.Delay {
+			total += delay
+			if delay < 100/tt.fps || delay > (100+tt.fps-1)/tt.fps {
+				t.Errorf("%d fps: frame %d delay %d", tt.fps, i, delay)
+			}
+			if got := anim.Image[i].At(5, 5); !sameColor(got, frameColors[i%len(frameColors)]) {
+				t.Errorf("%d fps: frame %d colour %v", tt.fps, i, got)
+			}
+		}
+		if total != tt.total {
+			t.Errorf("%d fps, %d frames: %d/100 s, want %d", tt.fps, tt.frames, total, tt.total)
+		}
+	}
+}
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// readPNGChunks splits a PNG file into chunks and checks their CRCs.
+func readPNGChunks(t *testing.T, file []byte) []pngChunk {
+	t.Helper()
+	if !bytes.HasPrefix(file, pngSignature) {
+		t.Fatal("no PNG signature")
+	}
+	var chunks []pngChunk
+	data := file[len(pngSignature):]
+	for len(data) > 0 {
+		length := binary.BigEndian.Uint32(data)
+		chunk := pngChunk{string(data[4:8]), data[8 : 8+length]}
+		if crc := binary.BigEndian.Uint32(data[8+length:]); crc != crc32.ChecksumIEEE(data[4:8+length]) {
+			t.Errorf("%s chunk has a bad CRC", chunk.kind)
+		}
+		chunks = append(chunks, chunk)
+		data = data[12+length:]
+	}
+	return chunks
+}
+
+// apngFrame rebuilds a plain PNG from the header and one frame's image data.
+func apngFrame(t *testing.T, header []byte, idat [][]byte) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	e := &apngEncoder{w: &buf}
+	buf.Write(pngSignature)
+	e.writeChunk("IHDR", header)
+	for _, data := range idat {
+		e.writeChunk("IDAT", data)
+	}
+	e.writeChunk("IEND", nil)
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAPNGEncoder(t *testing.T) {
+	for _, tt := range []struct{ fps, frames int }{{1, 1}, {10, 3}, {30, 5}, {50, 4}} {
+		file := encodeFrames(t, "apng", tt.fps, tt.frames)
+		
+		// Viewers without APNG support show the first frame
+		first, err := png.Decode(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameColor(first.At(5, 5), frameColors[0]) {
+			t.Errorf("%d fps: first frame colour %v", tt.fps, first.At(5, 5))
+		}
+		
+		chunks := readPNGChunks(t, file)
+		if chunks[0].kind != "IHDR" || chunks[1].kind != "acTL" || chunks[len(chunks)-1].kind != "IEND" {
+			t.Fatalf("%d fps: chunks start %s %s, end %s", tt.fps, chunks[0].kind, chunks[1].kind, chunks[len(chunks)-1].kind)
+		}
+		if frames := binary.BigEndian.Uint32(chunks[1].data); frames != uint32(tt.frames) {
+			t.Errorf("%d fps: acTL announces %d frames", tt.fps, frames)
+		}
+		
+		var frames [][][]byte
+		sequence := uint32(0)
+		for _, chunk := range chunks[2 : len(chunks)-1] {
+			switch chunk.kind {
+			case "fcTL":
+				num, den := binary.BigEndian.Uint16(chunk.data[20:]), binary.BigEndian.Uint16(chunk.data[22:])
+				if num != 1 || int(den) != tt.fps {
+					t.Errorf("%d fps: frame delay %d/%d", tt.fps, num, den)
+				}
+				frames = append(frames, nil)
+			case "IDAT":
+				frames[len(frames)-1] = append(frames[len(frames)-1], chunk.data)
+				continue
+			case "fdAT":
+				frames[len(frames)-1] = append(frames[len(frames)-1], chunk.data[4:])
+			default:
+				t.Fatalf("unexpected %s chunk", chunk.kind)
+			}
+			if got := binary.BigEndian.Uint32(chunk.data); got != sequence {
+				t.Errorf("%d fps: %s sequence %d, want %d", tt.fps, chunk.kind, got, sequence)
+			}
+			sequence++
+		}
+		
+		if len(frames) != tt.frames {
+			t.Fatalf("%d fps: %d frames, want %d", tt.fps, len(frames), tt.frames)
+		}
+		for i, idat := range frames {
+			if got := apngFrame(t, chunks[0].data, idat).At(5, 5); !sameColor(got, frameColors[i%len(frameColors)]) {
+				t.Errorf("%d fps: frame %d colour %v", tt.fps, i, got)
+			}
+		}
+	}
+}
+
+func TestAPNGEncoderErrors(t *testing.T) {
+	options := animationOptions{fps: 10}
+	
+	e := newAPNGEncoder(&bytes.Buffer{}, 2, options)
+	e.addFrame(solidFrame(frameColors[0], 10, 10))
+	if err := e.addFrame(solidFrame(frameColors[1], 20, 10)); err == nil {
+		t.Error("frame of a different size accepted")
+	}
+	
+	e = newAPNGEncoder(&bytes.Buffer{}, 3, options)
+	e.addFrame(solidFrame(frameColors[0], 10, 10))
+	if err := e.close(); err == nil {
+		t.Error("closed with fewer frames than announced")
+	}
+}
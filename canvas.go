@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Drawing surfaces. The chart and phase plot draw on a surface: the window's
+// ebiten.Image while running, or a canvas, an image.RGBA drawn on in plain
+// Go. Screenshots, the history animation and the phase plot image are
+// rendered on canvases, so they need no GPU and work in headless runs.
+
+type surface interface {
+	fillRect(x, y, width, height float32, c color.Color)
+	line(x1, y1, x2, y2 float32, c color.Color)
+	print(text string, x, y int)
+}
+
+// screenSurface draws with ebiten.
+type screenSurface struct {
+	*ebiten.Image
+}
+
+func (s screenSurface) fillRect(x, y, width, height float32, c color.Color) {
+	fillRectF(s.Image, x, y, width, height, c)
+}
+
+func (s screenSurface) line(x1, y1, x2, y2 float32, c color.Color) {
+	strokeLine(s.Image, x1, y1, x2, y2, c)
+}
+
+func (s screenSurface) print(text string, x, y int) {
+	ebitenutil.DebugPrintAt(s.Image, text, x, y)
+}
+
+// debugfont.png is the font of ebitenutil.DebugPrint (from the Ebitengine
+// sources, Apache License 2.0): 6x16 glyphs for U+0000 to U+00FF, 32 per row.
+//
+//go:embed debugfont.png
+var debugFontPNG []byte
+
+var debugFont = func() image.Image {
+	img, err := png.Decode(bytes.NewReader(debugFontPNG))
+	if err != nil {
+		panic(err)
+	}
+	return img
+}()
+
+const (
+	glyphWidth  = 6
+	glyphHeight = 16
+)
+
+// canvas is an image in memory with the same drawing operations as the
+// screen. Colours are premultiplied, as in ebiten, and blended over what is
+// already drawn.
+type canvas struct {
+	*image.RGBA
+}
+
+func newCanvas(width, height int) canvas {
+	return canvas{image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+func (c canvas) fill(col color.Color) {
+	draw.Draw(c.RGBA, c.Bounds(), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c canvas) fillRect(x, y, width, height float32, col color.Color) {
+	rect := image.Rect(int(math.Round(float64(x))), int(math.Round(float64(y))),
+		int(math.Round(float64(x+width))), int(math.Round(float64(y+height))))
+	
+	// Shapes smaller than a pixel still cover one, like on the screen
+	if rect.Dx() == 0 && width > 0 {
+		rect.Max.X++
+	}
+	if rect.Dy() == 0 && height > 0 {
+		rect.Max.Y++
+	}
+	draw.Draw(c.RGBA, rect, image.NewUniform(col), image.Point{}, draw.Over)
+}
+
+func (c canvas) plot(x, y int, col color.RGBA) {
+	if !(image.Point{x, y}).In(c.Rect) {
+		return
+	}
+	offset := c.PixOffset(x, y)
+	pix := c.Pix[offset : offset+4]
+	keep := 255 - uint32(col.A)
+	pix[0] = uint8(uint32(col.R) + uint32(pix[0])*keep/255)
+	pix[1] = uint8(uint32(col.G) + uint32(pix[1])*keep/255)
+	pix[2] = uint8(uint32(col.B) + uint32(pix[2])*keep/255)
+	pix[3] = uint8(uint32(col.A) + uint32(pix[3])*keep/255)
+}
+
+// line draws a one pixel wide line, one pixel per step along its longer
+// axis.
+func (c canvas) line(x1, y1, x2, y2 float32, col color.Color) {
+	rgba := color.RGBAModel.Convert(col).(color.RGBA)
+	dx, dy := float64(x2-x1), float64(y2-y1)
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy))))
+	if steps == 0 {
+		c.plot(int(x1), int(y1), rgba)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		c.plot(int(math.Floor(float64(x1)+t*dx)), int(math.Floor(float64(y1)+t*dy)), rgba)
+	}
+}
+
+// print draws text in the debug font, like ebitenutil.DebugPrintAt.
+func (c canvas) print(text string, x, y int) {
+	for row, line := range strings.Split(text, "\n") {
+		for col, r := range []rune(line) {
+			if r > 0xff {
+				r = '?'
+			}
+			glyph := image.Pt(int(r)%32*glyphWidth, int(r)/32*glyphHeight)
+			at := image.Pt(x+1+col*glyphWidth, y+row*glyphHeight)
+			draw.Draw(c.RGBA, image.Rectangle{at, at.Add(image.Pt(glyphWidth, glyphHeight))}, debugFont, glyph, draw.Over)
+		}
+	}
+}
+
+// drawScaled draws img with its top left corner at (x, y), each source pixel
+// covering scale x scale pixels.
+func (c canvas) drawScaled(img image.Image, x, y int, scale float64) {
+	bounds := img.Bounds()
+	width := int(float64(bounds.Dx()) * scale)
+	height := int(float64(bounds.Dy()) * scale)
+	for dy := 0; dy < height; dy++ {
+		sy := bounds.Min.Y + min(int(float64(dy)/scale), bounds.Dy()-1)
+		for dx := 0; dx < width; dx++ {
+			sx := bounds.Min.X + min(int(float64(dx)/scale), bounds.Dx()-1)
+			if (image.Point{x + dx, y + dy}).In(c.Rect) {
+				c.Set(x+dx, y+dy, img.At(sx, sy))
+			}
+		}
+	}
+}
+
+// imageFormat is the format of saved screenshots and plots, set with -image.
+type imageFormat struct {
+	name   string
+	ext    string
+	encode func(file *os.File, img image.Image) error
+}
+
+var imageFormats = []imageFormat{
+	{"jpeg", "jpg", func(file *os.File, img image.Image) error {
+		return jpeg.Encode(file, img, &jpeg.Options{Quality: 90})
+	}},
+	{"png", "png", func(file *os.File, img image.Image) error {
+		return png.Encode(file, img)
+	}},
+}
+
+// parseImageFormats turns a comma-separated list of format names into image
+// formats.
+func parseImageFormats(list string) ([]*imageFormat, error) {
+	var formats []*imageFormat
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		if name == "jpg" {
+			name = "jpeg"
+		}
+		
+		found := false
+		for i := range imageFormats {
+			if imageFormats[i].name == name {
+				formats = append(formats, &imageFormats[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown image format %q", name)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no image format given")
+	}
+	return formats, nil
+}
+
+func imageFormatNames() string {
+	names := make([]string, len(imageFormats))
+	for i, f := range imageFormats {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// saveImage writes img to basename with the extension of each format and
+// returns the names of the files written.
+func saveImage(basename string, img image.Image, formats []*imageFormat) ([]string, error) {
+	var written []string
+	for _, format := range formats {
+		filename := basename + "." + format.ext
+		file, err := os.Create(filename)
+		if err != nil {
+			return written, err
+		}
+		err = format.encode(file, img)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, err
+		}
+		written = append(written, filename)
+	}
+	return written, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+// Density-dependent population dynamics. Instead of refusing to breed only at
+// the global caps, reproduction probability falls as the neighbourhood fills
+// up or runs out of food, and crowded animals burn extra energy.
+
+func (w *World) countNearby(pos Position, radius int, cellType EntityType) (count, cells int) {
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			
+			x := pos.X + dx
+			y := pos.Y + dy
+			
+			if x < 0 || x >= w.Width || y < 0 || y >= w.Height {
+				continue
+			}
+			
+			cells++
+			if w.Grid[x][y] == cellType {
+				count++
+			}
+		}
+	}
+	
+	return count, cells
+}
+
+// crowding returns how full the neighbourhood around pos is for the species:
+// 0 means no conspecifics nearby, 1 means the local capacity is reached.
+func (w *World) crowding(pos Position, species EntityType) float64 {
+	count, _ := w.countNearby(pos, crowdingRadius, species)
+	
+	switch species {
+	case RabbitType:
+		return float64(count) / rabbitLocalCapacity
+	case FoxType:
+		return float64(count) / foxLocalCapacity
+	}
+	return 0
+}
+
+// foodAvailability returns 0-1 depending on how much food is in reach:
+// edible grass for rabbits, rabbits for foxes.
+func (w *World) foodAvailability(pos Position, species EntityType) float64 {
+	var food float64
+	
+	switch species {
+	case RabbitType:
+		grassCells := 0
+		for dx := -foodSearchRadius; dx <= foodSearchRadius; dx++ {
+			for dy := -foodSearchRadius; dy <= foodSearchRadius; dy++ {
+				grass, exists := w.Grass[Position{pos.X + dx, pos.Y + dy}]
+				if exists && grass.Amount >= w.Params.MinGrassToEat {
+					grassCells++
+				}
+			}
+		}
+		food = float64(grassCells) / rabbitFoodSaturation
+	case FoxType:
+		rabbits, _ := w.countNearby(pos, foodSearchRadius, RabbitType)
+		food = float64(rabbits) / foxFoodSaturation
+	}
+	
+	if food > 1 {
+		food = 1
+	}
+	return food
+}
+
+// reproductionFactor scales the base reproduction chance by local crowding
+// and food availability. It is 1 when the carrying capacity model is off.
+func (w *World) reproductionFactor(pos Position, species EntityType) float64 {
+	if !w.carryingCapacity {
+		return 1
+	}
+	
+	space := 1 - w.crowding(pos, species)
+	if space <= 0 {
+		return 0
+	}
+	
+	food := w.foodAvailability(pos, species)
+	return space * (minFoodFactor + (1-minFoodFactor)*food)
+}
+
+// crowdingEnergyCost is the extra energy an animal loses per metabolic step
+// because of competition with its neighbours.
+func (w *World) crowdingEnergyCost(pos Position, species EntityType) int {
+	if !w.carryingCapacity {
+		return 0
+	}
+	
+	return int(w.crowding(pos, species) * crowdingEnergyPenalty)
+}
+
+// Hard caps stay as a safety valve; with the carrying capacity model they are
+// raised so that the density dependence does the actual limiting.
+func (w *World) rabbitLimit() int {
+	if w.carryingCapacity {
+		return maxRabbitsSafety
+	}
+	return w.Params.MaxRabbits
+}
+
+func (w *World) foxLimit() int {
+	if w.carryingCapacity {
+		return maxFoxesSafety
+	}
+	return w.Params.MaxFoxes
+}
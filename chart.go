@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Population chart under the game area. Every series is drawn as a line over
+// a tick axis. The value axis is shared by all series, scaled per series so
+// each fills the chart height, or logarithmic. Hovering the chart shows the
+// exact values at the nearest recorded tick.
+
+type chartScale int
+
+const (
+	chartShared chartScale = iota
+	chartIndependent
+	chartLog
+)
+
+var chartScaleNames = []string{"shared", "per series", "log"}
+
+type chartSeries struct {
+	name  string
+	color color.RGBA
+	value func(data PopulationData) int
+}
+
+var populationSeries = []chartSeries{
+	{"Rabbits", color.RGBA{255, 255, 255, 255}, func(data PopulationData) int { return data.Rabbits }},
+	{"Foxes", color.RGBA{255, 0, 0, 255}, func(data PopulationData) int { return data.Foxes }},
+	{"Grass", color.RGBA{0, 255, 0, 255}, func(data PopulationData) int { return data.Grass }},
+}
+
+// chartGroups are the sets of series the time chart can show, one at a time.
+var chartGroups = []struct {
+	name   string
+	series []chartSeries
+}{
+	{"Populations", populationSeries},
+	{"Grass", []chartSeries{
+		{"Cells", color.RGBA{0, 255, 0, 255}, func(data PopulationData) int { return data.Grass }},
+		{"Biomass", color.RGBA{150, 200, 50, 255}, func(data PopulationData) int { return data.GrassBiomass }},
+	}},
+	{"Energy", []chartSeries{
+		{"R mean", color.RGBA{255, 255, 255, 255}, func(data PopulationData) int { return int(data.RabbitStats.MeanEnergy + 0.5) }},
+		{"R min", color.RGBA{130, 130, 130, 255}, func(data PopulationData) int { return data.RabbitStats.MinEnergy }},
+		{"R max", color.RGBA{255, 255, 150, 255}, func(data PopulationData) int { return data.RabbitStats.MaxEnergy }},
+		{"F mean", color.RGBA{255, 0, 0, 255}, func(data PopulationData) int { return int(data.FoxStats.MeanEnergy + 0.5) }},
+		{"F min", color.RGBA{140, 40, 40, 255}, func(data PopulationData) int { return data.FoxStats.MinEnergy }},
+		{"F max", color.RGBA{255, 150, 0, 255}, func(data PopulationData) int { return data.FoxStats.MaxEnergy }},
+	}},
+	{"Age", []chartSeries{
+		{"R mean", color.RGBA{255, 255, 255, 255}, func(data PopulationData) int { return int(data.RabbitStats.MeanAge + 0.5) }},
+		{"R min", color.RGBA{130, 130, 130, 255}, func(data PopulationData) int { return data.RabbitStats.MinAge }},
+		{"R max", color.RGBA{255, 255, 150, 255}, func(data PopulationData) int { return data.RabbitStats.MaxAge }},
+		{"F mean", color.RGBA{255, 0, 0, 255}, func(data PopulationData) int { return int(data.FoxStats.MeanAge + 0.5) }},
+		{"F min", color.RGBA{140, 40, 40, 255}, func(data PopulationData) int { return data.FoxStats.MinAge }},
+		{"F max", color.RGBA{255, 150, 0, 255}, func(data PopulationData) int { return data.FoxStats.MaxAge }},
+	}},
+	{"Births & deaths", []chartSeries{
+		{"R born", color.RGBA{255, 255, 255, 255}, func(data PopulationData) int { return data.RecentEvents.RabbitBirths }},
+		{"F born", color.RGBA{255, 0, 0, 255}, func(data PopulationData) int { return data.RecentEvents.FoxBirths }},
+		{"Eaten", color.RGBA{255, 150, 0, 255}, func(data PopulationData) int { return data.RecentEvents.RabbitsEaten }},
+		{"R starved", color.RGBA{130, 130, 130, 255}, func(data PopulationData) int { return data.RecentEvents.RabbitsStarved }},
+		{"F starved", color.RGBA{140, 40, 40, 255}, func(data PopulationData) int { return data.RecentEvents.FoxesStarved }},
+		{"Hunts", color.RGBA{0, 200, 255, 255}, func(data PopulationData) int { return data.Hunts }},
+	}},
+}
+
+// populationChart maps ticks and values to pixels for one drawing of the
+// chart.
+type populationChart struct {
+	left, top, right, bottom int
+	firstTick, lastTick      int
+	scale                    chartScale
+	series                   []chartSeries
+	max                      []int // Largest value of each series
+	axisMax                  int   // Top of the shared and log value axes
+}
+
+// newPopulationChart lays out a chart in the graph area for the given history
+// and tick range.
+func newPopulationChart(history []PopulationData, series []chartSeries, firstTick, lastTick int, scale chartScale) *populationChart {
+	c := &populationChart{
+		left:      graphOffsetX + 40,
+		top:       graphOffsetY + 8,
+		right:     graphOffsetX + graphWidth - 8,
+		bottom:    graphOffsetY + graphHeight - 20,
+		firstTick: firstTick,
+		lastTick:  lastTick,
+		scale:     scale,
+		series:    series,
+		max:       make([]int, len(series)),
+	}
+	if c.lastTick <= c.firstTick {
+		c.lastTick = c.firstTick + 1
+	}
+	
+	largest := 10
+	for _, data := range history {
+		for i, series := range c.series {
+			value := series.value(data)
+			if value > c.max[i] {
+				c.max[i] = value
+			}
+			if value > largest {
+				largest = value
+			}
+		}
+	}
+	
+	if scale == chartLog {
+		c.axisMax = int(math.Pow(10, math.Ceil(math.Log10(float64(largest)))))
+	} else {
+		step := niceStep(float64(largest), 4)
+		c.axisMax = int(math.Ceil(float64(largest)/step) * step)
+	}
+	return c
+}
+
+// niceStep returns a round step (1, 2 or 5 times a power of ten) that splits
+// span into about count parts.
+func niceStep(span float64, count int) float64 {
+	raw := span / float64(count)
+	if raw <= 0 {
+		return 1
+	}
+	
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*magnitude >= raw {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func (c *populationChart) x(tick int) float32 {
+	fraction := float64(tick-c.firstTick) / float64(c.lastTick-c.firstTick)
+	return float32(float64(c.left) + fraction*float64(c.right-c.left))
+}
+
+// y returns the height of a value of the given series.
+func (c *populationChart) y(series, value int) float32 {
+	var fraction float64
+	switch c.scale {
+	case chartShared:
+		fraction = float64(value) / float64(c.axisMax)
+	case chartIndependent:
+		if c.max[series] > 0 {
+			fraction = float64(value) / float64(c.max[series])
+		}
+	case chartLog:
+		fraction = math.Log10(1+float64(value)) / math.Log10(1+float64(c.axisMax))
+	}
+	return float32(float64(c.bottom) - fraction*float64(c.bottom-c.top))
+}
+
+func (c *populationChart) inside(x, y int) bool {
+	return x >= c.left && x <= c.right && y >= c.top && y <= c.bottom
+}
+
+// nearest returns the index of the history entry closest to screen column x.
+func (c *populationChart) nearest(history []PopulationData, x int) int {
+	best, bestDistance := 0, math.MaxFloat64
+	for i, data := range history {
+		distance := math.Abs(float64(c.x(data.Tick)) - float64(x))
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+func (c *populationChart) drawAxes(s surface) {
+	gridColor := color.RGBA{50, 50, 50, 255}
+	axisColor := color.RGBA{140, 140, 140, 255}
+	
+	// Value axis
+	switch c.scale {
+	case chartShared:
+		step := niceStep(float64(c.axisMax), 4)
+		for value := 0.0; value <= float64(c.axisMax); value += step {
+			c.drawValueLabel(s, c.y(0, int(value)), fmt.Sprintf("%d", int(value)), gridColor)
+		}
+	case chartIndependent:
+		for percent := 0; percent <= 100; percent += 25 {
+			y := float32(c.bottom) - float32(percent)/100*float32(c.bottom-c.top)
+			c.drawValueLabel(s, y, fmt.Sprintf("%d%%", percent), gridColor)
+		}
+	case chartLog:
+		c.drawValueLabel(s, c.y(0, 0), "0", gridColor)
+		for value := 1; value <= c.axisMax; value *= 10 {
+			c.drawValueLabel(s, c.y(0, value), fmt.Sprintf("%d", value), gridColor)
+		}
+	}
+	
+	// Time axis
+	for _, tick := range c.tickLabels() {
+		x := c.x(tick)
+		s.line(x, float32(c.top), x, float32(c.bottom), gridColor)
+		label := fmt.Sprintf("%d", tick)
+		s.print(label, int(x)-len(label)*3, c.bottom+2)
+	}
+	
+	s.line(float32(c.left), float32(c.top), float32(c.left), float32(c.bottom), axisColor)
+	s.line(float32(c.left), float32(c.bottom), float32(c.right), float32(c.bottom), axisColor)
+}
+
+// tickLabels returns the ticks labelled on the time axis: round numbers from
+// firstTick to lastTick, at least one tick apart.
+func (c *populationChart) tickLabels() []int {
+	var ticks []int
+	step := max(1, int(niceStep(float64(c.lastTick-c.firstTick), 6)))
+	for tick := (c.firstTick + step - 1) / step * step; tick <= c.lastTick; tick += step {
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+func (c *populationChart) drawValueLabel(s surface, y float32, label string, gridColor color.RGBA) {
+	s.line(float32(c.left), y, float32(c.right), y, gridColor)
+	s.print(label, c.left-4-len(label)*6, int(y)-8)
+}
+
+// drawSeries draws one series as connected lines. Entries that fall in the
+// same pixel column are merged into a vertical span, so long histories cost
+// no more than the chart is wide.
+func (c *populationChart) drawSeries(s surface, history []PopulationData, series int) {
+	lineColor := c.series[series].color
+	value := c.series[series].value
+	
+	var prevX, prevY, spanLow, spanHigh float32
+	for i, data := range history {
+		x := float32(math.Round(float64(c.x(data.Tick))))
+		y := c.y(series, value(data))
+		
+		if i > 0 && x == prevX {
+			spanLow = float32(math.Max(float64(spanLow), float64(y)))
+			spanHigh = float32(math.Min(float64(spanHigh), float64(y)))
+			prevY = y
+			continue
+		}
+		
+		if i > 0 {
+			if spanLow != spanHigh {
+				s.line(prevX, spanLow, prevX, spanHigh, lineColor)
+			}
+			s.line(prevX, prevY, x, y, lineColor)
+		}
+		prevX, prevY = x, y
+		spanLow, spanHigh = y, y
+	}
+	if spanLow != spanHigh {
+		s.line(prevX, spanLow, prevX, spanHigh, lineColor)
+	}
+	
+	if len(history) == 1 {
+		s.fillRect(prevX-1, prevY-1, 3, 3, lineColor)
+	}
+}
+
+func (c *populationChart) draw(s surface, history []PopulationData) {
+	c.drawAxes(s)
+	for i := range c.series {
+		c.drawSeries(s, history, i)
+	}
+}
+
+// drawLegend draws a colour swatch and name for each series, followed by the
+// scale in use.
+func (c *populationChart) drawLegend(s surface, title string, x, y int) {
+	s.print(title+":", x, y)
+	x += len(title)*6 + 12
+	for i, series := range c.series {
+		s.fillRect(float32(x), float32(y+4), 10, 10, series.color)
+		label := series.name
+		if c.scale == chartIndependent {
+			label += fmt.Sprintf(" (max %d)", c.max[i])
+		}
+		s.print(label, x+14, y)
+		x += 14 + len(label)*6 + 16
+	}
+	s.print("Scale: "+chartScaleNames[c.scale], x, y)
+}
+
+// drawTooltip marks the recorded tick nearest to column x and shows the
+// values of every series at it.
+func (c *populationChart) drawTooltip(s surface, history []PopulationData, x int) {
+	data := history[c.nearest(history, x)]
+	markerX := c.x(data.Tick)
+	s.line(markerX, float32(c.top), markerX, float32(c.bottom), color.RGBA{200, 200, 200, 255})
+	
+	text := fmt.Sprintf("Tick %d", data.Tick)
+	for i, series := range c.series {
+		value := series.value(data)
+		s.fillRect(markerX-2, c.y(i, value)-2, 5, 5, series.color)
+		text += fmt.Sprintf("\n%s: %d", series.name, value)
+	}
+	
+	boxWidth, boxHeight := 100, 16*(len(c.series)+1)+4
+	boxX := int(markerX) + 8
+	if boxX+boxWidth > c.right {
+		boxX = int(markerX) - 8 - boxWidth
+	}
+	s.fillRect(float32(boxX), float32(c.top), float32(boxWidth), float32(boxHeight), color.RGBA{30, 30, 40, 230})
+	s.print(text, boxX+4, c.top+2)
+}
+
+func (g *Game) populationChart(history []PopulationData) *populationChart {
+	series := chartGroups[g.chartGroup].series
+	return newPopulationChart(history, series, history[0].Tick, history[len(history)-1].Tick, g.chartScale)
+}
+
+func (g *Game) cycleChartGroup() {
+	g.chartGroup = (g.chartGroup + 1) % len(chartGroups)
+	log.Printf("Chart series: %s", chartGroups[g.chartGroup].name)
+}
+
+func (g *Game) cycleChartScale() {
+	g.chartScale = (g.chartScale + 1) % chartScale(len(chartScaleNames))
+	log.Printf("Chart scale: %s", chartScaleNames[g.chartScale])
+}
+
+// drawChartLegend draws the legend of the current chart view under the graph.
+func (g *Game) drawChartLegend(screen *ebiten.Image) {
+	s := screenSurface{screen}
+	history := g.history.downsampled(historyDisplayPoints)
+	if len(history) < 1 {
+		return
+	}
+	
+	if g.chartView != viewTimeSeries {
+		text := fmt.Sprintf("Phase space: %s (dark = older, white = now)", chartViewNames[g.chartView])
+		s.print(text, 30, screenHeight-22)
+		return
+	}
+	g.populationChart(history).drawLegend(s, chartGroups[g.chartGroup].name, 30, screenHeight-22)
+}
+
+// drawChartHover shows the tooltip when the cursor is over the chart. It is
+// drawn last so nothing covers it.
+func (g *Game) drawChartHover(screen *ebiten.Image) {
+	s := screenSurface{screen}
+	history := g.history.downsampled(historyDisplayPoints)
+	if len(history) < 1 {
+		return
+	}
+	
+	x, y := ebiten.CursorPosition()
+	if g.drawMode != "none" && insideToolbar(x, y) {
+		return
+	}
+	if g.chartView != viewTimeSeries {
+		if plot := g.phasePlot(history); plot.inside(x, y) {
+			plot.drawTooltip(s, history, x, y)
+		}
+		return
+	}
+	
+	if chart := g.populationChart(history); chart.inside(x, y) {
+		chart.drawTooltip(s, history, x)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Short histories must still give a time axis from the first tick to the
+// last, with labels at least one tick apart.
+func TestPopulationChartShortHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []PopulationData
+		labels  []int
+	}{
+		{"one sample", []PopulationData{{Tick: 0, Rabbits: 10, Foxes: 5, Grass: 30}}, []int{0, 1}},
+		{"one sample later in the run", []PopulationData{{Tick: 30, Rabbits: 10}}, []int{30, 31}},
+		{"two samples", []PopulationData{{Tick: 0, Rabbits: 10, Foxes: 5, Grass: 30}, {Tick: 1, Rabbits: 12, Foxes: 4, Grass: 28}}, []int{0, 1}},
+		{"span of three ticks", []PopulationData{{Tick: 0, Rabbits: 10}, {Tick: 3, Rabbits: 11}}, []int{0, 1, 2, 3}},
+	}
+	
+	for _, tt := range tests {
+		for scale := range chartScaleNames {
+			c := newPopulationChart(tt.history, populationSeries, tt.history[0].Tick, tt.history[len(tt.history)-1].Tick, chartScale(scale))
+			c.draw(newCanvas(screenWidth, screenHeight), tt.history)
+			
+			labels := c.tickLabels()
+			if !reflect.DeepEqual(labels, tt.labels) {
+				t.Errorf("%s, %s scale: time axis labels %v, want %v", tt.name, chartScaleNames[scale], labels, tt.labels)
+			}
+			for _, tick := range labels {
+				if x := c.x(tick); x < float32(c.left) || x > float32(c.right) {
+					t.Errorf("%s: label %d at x %v, outside %d..%d", tt.name, tick, x, c.left, c.right)
+				}
+			}
+		}
+	}
+}
+
+func TestNiceStep(t *testing.T) {
+	tests := []struct {
+		span  float64
+		count int
+		want  float64
+	}{
+		{0, 6, 1},
+		{10, 4, 5},
+		{100, 4, 50},
+		{1000, 6, 200},
+		{3000, 6, 500},
+	}
+	
+	for _, tt := range tests {
+		if got := niceStep(tt.span, tt.count); got != tt.want {
+			t.Errorf("niceStep(%v, %d) = %v, want %v", tt.span, tt.count, got, tt.want)
+		}
+	}
+}
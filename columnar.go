@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+)
+
+// Columnar binary export: every column is stored as one contiguous block, so a
+// notebook can load a column straight into an array without parsing text.
+//
+// Layout, all integers little-endian:
+//
+//	magic       "ECOCOL01"
+//	metadata    uint32 length + JSON
+//	table count uint32
+//	per table:  name, uint32 rows, uint32 columns
+//	per column: name, uint8 type, data
+//
+// Names are a uint16 length followed by UTF-8 bytes. Type 1 is int64, 2 is
+// float64 (rows x 8 bytes each); type 3 is text, stored as rows uint32 lengths
+// followed by the concatenated bytes. The file has two tables, "samples" and
+// "events". readColumnar reads it back; -view uses it to open .col files.
+
+const columnarMagic = "ECOCOL01"
+
+const (
+	columnarInt   = 1
+	columnarFloat = 2
+	columnarText  = 3
+)
+
+type columnarColumn struct {
+	name   string
+	kind   columnKind
+	ints   []int64
+	floats []float64
+	texts  []string
+}
+
+type columnarTable struct {
+	name    string
+	rows    int
+	columns []*columnarColumn
+}
+
+func newColumnarTable(name string, columns []exportColumn) *columnarTable {
+	table := &columnarTable{name: name}
+	for _, column := range columns {
+		table.columns = append(table.columns, &columnarColumn{name: column.name, kind: column.kind})
+	}
+	return table
+}
+
+// addRow appends one value to each column; values must match the column kinds.
+func (t *columnarTable) addRow(values ...any) {
+	for i, value := range values {
+		column := t.columns[i]
+		switch v := value.(type) {
+		case int:
+			column.ints = append(column.ints, int64(v))
+		case float64:
+			column.floats = append(column.floats, v)
+		case string:
+			column.texts = append(column.texts, v)
+		}
+	}
+	t.rows++
+}
+
+func (t *columnarTable) writeTo(w io.Writer) error {
+	writeName(w, t.name)
+	binary.Write(w, binary.LittleEndian, uint32(t.rows))
+	binary.Write(w, binary.LittleEndian, uint32(len(t.columns)))
+	for _, column := range t.columns {
+		writeName(w, column.name)
+		switch column.kind {
+		case intColumn:
+			binary.Write(w, binary.LittleEndian, uint8(columnarInt))
+			binary.Write(w, binary.LittleEndian, column.ints)
+		case floatColumn:
+			binary.Write(w, binary.LittleEndian, uint8(columnarFloat))
+			bits := make([]uint64, len(column.floats))
+			for i, f := range column.floats {
+				bits[i] = math.Float64bits(f)
+			}
+			binary.Write(w, binary.LittleEndian, bits)
+		case textColumn:
+			binary.Write(w, binary.LittleEndian, uint8(columnarText))
+			lengths := make([]uint32, len(column.texts))
+			for i, text := range column.texts {
+				lengths[i] = uint32(len(text))
+			}
+			binary.Write(w, binary.LittleEndian, lengths)
+			for _, text := range column.texts {
+				if _, err := io.WriteString(w, text); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeName(w io.Writer, name string) {
+	binary.Write(w, binary.LittleEndian, uint16(len(name)))
+	io.WriteString(w, name)
+}
+
+// eventColumns describes the event log table for the typed formats.
+var eventColumns = []exportColumn{
+	{name: "Tick", kind: intColumn},
+	{name: "Event", kind: textColumn},
+	{name: "Count", kind: intColumn},
+	{name: "Detail", kind: textColumn},
+}
+
+func writeColumnar(export *exportRun) error {
+	samples := newColumnarTable("samples", export.columns)
+	values := make([]any, len(export.columns))
+	err := export.history.each(func(data PopulationData) error {
+		for i, column := range export.columns {
+			values[i] = column.value(data)
+		}
+		samples.addRow(values...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	events := newColumnarTable("events", eventColumns)
+	for _, event := range export.events {
+		events.addRow(event.Tick, event.Event, event.Count, event.Detail)
+	}
+	
+	meta, err := json.Marshal(export.meta)
+	if err != nil {
+		return err
+	}
+	
+	filename := export.dataFilename("col")
+	file, done, err := createExportFile(filename)
+	if err != nil {
+		return err
+	}
+	file.WriteString(columnarMagic)
+	binary.Write(file, binary.LittleEndian, uint32(len(meta)))
+	file.Write(meta)
+	binary.Write(file, binary.LittleEndian, uint32(2))
+	for _, table := range []*columnarTable{samples, events} {
+		if err = table.writeTo(file); err != nil {
+			break
+		}
+	}
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+	
+	log.Printf("Columnar data exported to: %s (%d samples, %d events)", filename, samples.rows, events.rows)
+	return nil
+}
+
+// readColumnar reads a file written by writeColumnar and returns its metadata
+// and tables by name.
+func readColumnar(r io.Reader) (RunMetadata, map[string]*columnarTable, error) {
+	var meta RunMetadata
+	magic := make([]byte, len(columnarMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return meta, nil, err
+	}
+	if string(magic) != columnarMagic {
+		return meta, nil, fmt.Errorf("not a columnar export (magic %q)", magic)
+	}
+	
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return meta, nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return meta, nil, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, nil, fmt.Errorf("metadata: %w", err)
+	}
+	
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return meta, nil, err
+	}
+	tables := make(map[string]*columnarTable, count)
+	for i := uint32(0); i < count; i++ {
+		table, err := readColumnarTable(r)
+		if err != nil {
+			return meta, nil, err
+		}
+		tables[table.name] = table
+	}
+	return meta, tables, nil
+}
+
+func readColumnarTable(r io.Reader) (*columnarTable, error) {
+	name, err := readName(r)
+	if err != nil {
+		return nil, err
+	}
+	var rows, columns uint32
+	binary.Read(r, binary.LittleEndian, &rows)
+	if err := binary.Read(r, binary.LittleEndian, &columns); err != nil {
+		return nil, fmt.Errorf("table %s: %w", name, err)
+	}
+	
+	table := &columnarTable{name: name, rows: int(rows)}
+	for i := uint32(0); i < columns; i++ {
+		column := &columnarColumn{}
+		if column.name, err = readName(r); err != nil {
+			return nil, fmt.Errorf("table %s: %w", name, err)
+		}
+		var kind uint8
+		binary.Read(r, binary.LittleEndian, &kind)
+		switch kind {
+		case columnarInt:
+			column.kind = intColumn
+			column.ints = make([]int64, rows)
+			err = binary.Read(r, binary.LittleEndian, column.ints)
+		case columnarFloat:
+			column.kind = floatColumn
+			bits := make([]uint64, rows)
+			err = binary.Read(r, binary.LittleEndian, bits)
+			column.floats = make([]float64, rows)
+			for j, b := range bits {
+				column.floats[j] = math.Float64frombits(b)
+			}
+		case columnarText:
+			column.kind = textColumn
+			lengths := make([]uint32, rows)
+			err = binary.Read(r, binary.LittleEndian, lengths)
+			for _, n := range lengths {
+				if err != nil {
+					break
+				}
+				text := make([]byte, n)
+				_, err = io.ReadFull(r, text)
+				column.texts = append(column.texts, string(text))
+			}
+		default:
+			return nil, fmt.Errorf("table %s, column %s: unknown type %d", name, column.name, kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("table %s, column %s: %w", name, column.name, err)
+		}
+		table.columns = append(table.columns, column)
+	}
+	return table, nil
+}
+
+func readName(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	name := make([]byte, length)
+	_, err := io.ReadFull(r, name)
+	return string(name), err
+}
+
+// text returns a value of the column as it would be written to a CSV file.
+func (c *columnarColumn) text(row int) string {
+	switch c.kind {
+	case intColumn:
+		return strconv.FormatInt(c.ints[row], 10)
+	case floatColumn:
+		return strconv.FormatFloat(c.floats[row], 'g', -1, 64)
+	}
+	return c.texts[row]
+}
@@ -1,44 +1,167 @@
 package main
 
+import "time"
+
 const (
 	screenWidth  = 800
 	screenHeight = 600
-	gridWidth    = 80
+	gridWidth    = 80 // Default grid size, overridden with -width and -height
 	gridHeight   = 60
-	cellSize     = 10
-
+	minGridSize  = 16
+	maxCellSize  = 10 // Cells shrink below this to fit the grid in the game area
+	
 	gameAreaHeight = 400
 	graphHeight    = 150
 	graphWidth     = 750
 	graphOffsetX   = 25
 	graphOffsetY   = 420
-
-	maxHistoryPoints = 150
-
-	maxGrassAmount   = 100
-	grassGrowthRate  = 2
-	grassSpawnChance = 0.01
-
+	timelineY      = 403
+	timelineHeight = 12
+	
+	inspectPanelX        = 560
+	inspectPanelY        = 90
+	inspectPanelWidth    = 230
+	inspectPanelHeight   = 300
+	inspectHistoryLength = 120 // Ticks of history kept for the selected entity
+	
+	toolbarX            = 500 // Editor toolbar, over the right part of the chart
+	toolbarY            = graphOffsetY + 8
+	toolbarWidth        = 272
+	toolbarHeight       = 70
+	toolbarButtonHeight = 20
+	maxBrushRadius      = 10
+	maxUndoSteps        = 100
+	
+	settingsPanelX      = 520
+	settingsPanelY      = 90
+	settingsPanelWidth  = 270
+	settingsRowHeight   = 18
+	settingsNameWidth   = 96
+	settingsValueWidth  = 44
+	settingsStepWidth   = 14
+	settingsSliderWidth = 82
+	
+	maxHistoryPoints      = 150   // Frames in the saved history sequence
+	maxAnimationFPS       = 50    // A GIF delay of 2/100 s, the shortest browsers play as written
+	historyDisplayPoints  = 1000  // Points drawn in the population chart
+	historyMemoryPoints   = 20000 // Samples kept in memory before spilling to disk
+	defaultSampleInterval = 30    // Ticks between population samples
+	
+	defaultSpeedLevel   = 3  // Index into speedLevels: one tick every 10 frames
+	maxSpeedFrameMillis = 12 // Time per frame spent on ticks at max speed
+	
+	snapshotInterval = 10  // Ticks between timeline snapshots
+	snapshotCapacity = 300 // Snapshots kept for rewinding (3000 ticks)
+	
+	maxGrassAmount     = 100
+	grassGrowthRate    = 2
+	grassSpawnChance   = 0.01
+	grassSpawnAttempts = 10 // Cells tried per tick on the default grid, scaled with area
+	
 	rabbitMoveChance = 0.7
 	rabbitEnergyLoss = 1
 	grassEnergyGain  = 40
 	minGrassToEat    = 5
-
+	
 	reproduceEnergyThreshold = 60
 	reproductionCooldown     = 180
 	reproduceChance          = 0.3
-
+	
 	foxMoveChance         = 0.6
 	foxEnergyLoss         = 1
 	rabbitEnergyGain      = 50
 	foxReproduceThreshold = 70
-
+	
+	// Update all animals from one snapshot instead of one after another
+	synchronousUpdate = false
+	
+	// Parallel update (uses the synchronous rules), enabled with more than one worker
+	parallelWorkers  = 1
+	parallelTileSize = 64 // cells per side of the square tiles handed to workers
+	
 	foxVisionRange  = 3
 	foxSmartHunting = true
-
+	
+	// Scent trails left by rabbits, followed by foxes with no rabbit in view
+	scentTrackingEnabled = true
+	scentDeposit         = 1.0
+	scentDecay           = 0.95 // fraction of scent left after each tick
+	scentThreshold       = 0.05
+	maxScent             = 10.0
+	
+	// Pack hunting: nearby foxes pick a common target, surround it and share kills
+	packHuntingEnabled = false
+	foxPackRadius      = 4
+	packShareFraction  = 0.5 // part of a kill's energy handed to nearby packmates
+	
+	// Rabbit herding (boids): weights of the terms scoring each move
+	herdingEnabled    = false
+	herdRadius        = 4
+	cohesionWeight    = 1.0
+	separationWeight  = 1.5
+	alignmentWeight   = 0.8
+	forageWeight      = 1.2
+	fleeWeight        = 3.0
+	herdNoise         = 0.5
+	rabbitVisionRange = 2
+	herdVigilanceStep = 3 // herd mates needed for each extra cell of vigilance
+	maxVigilanceBonus = 3
+	
+	// Regions and seasonal migration
+	regionsEnabled   = false
+	migrationEnabled = false
+	regionSeeds      = 9   // Voronoi seeds used to lay out the regions
+	seasonLength     = 600 // ticks per season
+	migrationBias    = 0.5 // chance that a wandering animal heads for its preferred region
+	
+	// Continuous metabolism: energy cost per tick of each activity. Idle cost
+	// matches the old flat loss of 1 energy every 60 ticks
+	continuousMetabolismEnabled = true
+	rabbitIdleCost              = 1.0 / 60
+	rabbitMoveCost              = 1.5 / 60
+	rabbitDigestCost            = 0.5 / 60
+	foxIdleCost                 = 1.0 / 60
+	foxMoveCost                 = 1.5 / 60
+	foxChaseCost                = 2.5 / 60
+	foxDigestCost               = 0.5 / 60
+	digestTicks                 = 30
+	
+	// Hunting success: chance that a fox reaching a rabbit catches it
+	catchBaseChance     = 0.6
+	catchEnergyWeight   = 0.3 // effect of fox vs rabbit condition (energy relative to max)
+	catchAgeBonus       = 0.15
+	youngAge            = 300  // rabbits younger than this are easier to catch
+	elderAge            = 3000 // foxes older than this are slower hunters
+	terrainCoverEnabled = true
+	grassCoverWeight    = 0.2 // with full grass on the rabbit's cell and its neighbours
+	forestCover         = 0.15
+	minCatchChance      = 0.05
+	maxCatchChance      = 0.95
+	huntFailCost        = 3
+	
+	// Gestation: mating starts a pregnancy that ends with a litter
+	gestationEnabled     = true
+	rabbitGestationTicks = 60
+	foxGestationTicks    = 120
+	birthSearchRadius    = 3 // how far from the mother newborns may be placed
+	
 	// Population limits prevent overpopulation
 	maxRabbits = 50
 	maxFoxes   = 15
+	
+	// Carrying capacity model: reproduction and metabolism depend on local
+	// crowding and food, hard caps are only a safety valve
+	carryingCapacityEnabled = false
+	crowdingRadius          = 3
+	rabbitLocalCapacity     = 10 // rabbits in the neighbourhood that stop breeding
+	foxLocalCapacity        = 3
+	foodSearchRadius        = 3
+	rabbitFoodSaturation    = 6 // edible grass cells counted as plenty of food
+	foxFoodSaturation       = 3 // rabbits in reach counted as plenty of food
+	minFoodFactor           = 0.2
+	crowdingEnergyPenalty   = 2 // extra energy loss per step when at capacity
+	maxRabbitsSafety        = 400
+	maxFoxesSafety          = 100
 )
 
 type EntityType int
@@ -56,7 +179,20 @@ type Position struct {
 
 type PopulationData struct {
 	Tick    int
+	Time    time.Time // Wall-clock time the sample was taken
 	Rabbits int
 	Foxes   int
 	Grass   int
+	Regions []RegionCount // Per-region counts, indexed like World.Regions
+	
+	HuntAttempts  int // Cumulative since the start of the run
+	HuntSuccesses int
+	
+	GrassBiomass int // Total amount of grass on the map
+	RabbitStats  SpeciesStats
+	FoxStats     SpeciesStats
+	
+	Events       PopulationEvents // Cumulative since the start of the run
+	RecentEvents PopulationEvents // Since the previous sample
+	Hunts        int              // Hunt attempts since the previous sample
 }
\ No newline at end of file
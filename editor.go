@@ -0,0 +1,518 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// World editor. The draw modes are tools that paint with a round brush:
+// every cell under the brush gets a rabbit, fox or grass with the chance set
+// by the brush density, terrain repaints the region map and the eraser clears
+// the cell. Dragging paints each cell once per stroke. Every stroke records
+// the cells it changed so it can be undone and redone; undo puts back only
+// what the stroke changed: the grass, the region, the animals it erased, and
+// takes back the animals it placed. Whatever the simulation did to those
+// cells since is kept.
+
+type cellState struct {
+	grass  *Grass
+	rabbit *Rabbit
+	fox    *Fox
+	region int
+}
+
+type cellEdit struct {
+	pos    Position
+	before cellState
+	after  cellState
+}
+
+type editStroke struct {
+	edits   []cellEdit
+	terrain bool // The stroke changed the region map
+}
+
+type toolButton struct {
+	x, y, width int
+	tool        string // Draw mode selected by the button, if any
+	label       func(g *Game) string
+	action      func(g *Game)
+}
+
+// The toolbar sits in the right part of the chart panel, so it covers no
+// cells of the world.
+var toolbarButtons = []toolButton{
+	{toolbarX, toolbarY, 52, "rabbit", func(*Game) string { return "Rabbit" }, nil},
+	{toolbarX + 55, toolbarY, 52, "fox", func(*Game) string { return "Fox" }, nil},
+	{toolbarX + 110, toolbarY, 52, "grass", func(*Game) string { return "Grass" }, nil},
+	{toolbarX + 165, toolbarY, 52, "terrain", func(*Game) string { return "Terrain" }, nil},
+	{toolbarX + 220, toolbarY, 52, "eraser", func(*Game) string { return "Eraser" }, nil},
+	{toolbarX, toolbarY + 25, 20, "", func(*Game) string { return "-" }, func(g *Game) { g.changeBrushRadius(-1) }},
+	{toolbarX + 23, toolbarY + 25, 84, "", func(g *Game) string { return fmt.Sprintf("Radius %d", g.brushRadius) }, nil},
+	{toolbarX + 110, toolbarY + 25, 20, "", func(*Game) string { return "+" }, func(g *Game) { g.changeBrushRadius(1) }},
+	{toolbarX + 133, toolbarY + 25, 20, "", func(*Game) string { return "-" }, func(g *Game) { g.changeBrushDensity(-0.1) }},
+	{toolbarX + 156, toolbarY + 25, 93, "", func(g *Game) string { return fmt.Sprintf("Density %d%%", int(g.brushDensity*100+0.5)) }, nil},
+	{toolbarX + 252, toolbarY + 25, 20, "", func(*Game) string { return "+" }, func(g *Game) { g.changeBrushDensity(0.1) }},
+	{toolbarX, toolbarY + 50, 20, "", func(*Game) string { return "<" }, func(g *Game) { g.cycleTerrain(-1) }},
+	{toolbarX + 23, toolbarY + 50, 84, "", func(g *Game) string { return g.world.Regions[g.terrainRegion].Name }, nil},
+	{toolbarX + 110, toolbarY + 50, 20, "", func(*Game) string { return ">" }, func(g *Game) { g.cycleTerrain(1) }},
+	{toolbarX + 133, toolbarY + 50, 68, "", func(g *Game) string { return fmt.Sprintf("Undo %d", len(g.undoStack)) }, func(g *Game) { g.undo() }},
+	{toolbarX + 204, toolbarY + 50, 68, "", func(g *Game) string { return fmt.Sprintf("Redo %d", len(g.redoStack)) }, func(g *Game) { g.redo() }},
+}
+
+func insideToolbar(x, y int) bool {
+	return x >= toolbarX-4 && x < toolbarX+toolbarWidth+4 && y >= toolbarY-4 && y < toolbarY+toolbarHeight+4
+}
+
+func (g *Game) setDrawMode(mode string) {
+	g.drawMode = mode
+	log.Printf("Draw mode: %s", mode)
+}
+
+func (g *Game) changeBrushRadius(delta int) {
+	g.brushRadius += delta
+	if g.brushRadius < 0 {
+		g.brushRadius = 0
+	}
+	if g.brushRadius > maxBrushRadius {
+		g.brushRadius = maxBrushRadius
+	}
+}
+
+func (g *Game) changeBrushDensity(delta float64) {
+	g.brushDensity += delta
+	if g.brushDensity < 0.1 {
+		g.brushDensity = 0.1
+	}
+	if g.brushDensity > 1 {
+		g.brushDensity = 1
+	}
+}
+
+func (g *Game) cycleTerrain(delta int) {
+	count := len(g.world.Regions)
+	g.terrainRegion = (g.terrainRegion + delta + count) % count
+}
+
+func (g *Game) handleEditorInput() {
+	if inpututil.IsKeyJustPressed(ebiten.Key3) {
+		g.setDrawMode("grass")
+	}
+	if inpututil.IsKeyJustPressed(ebiten.Key4) {
+		g.setDrawMode("terrain")
+	}
+	if inpututil.IsKeyJustPressed(ebiten.Key5) {
+		g.setDrawMode("eraser")
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		g.changeBrushRadius(-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		g.changeBrushRadius(1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySemicolon) {
+		g.changeBrushDensity(-0.1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyQuote) {
+		g.changeBrushDensity(0.1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		g.cycleTerrain(1)
+	}
+	
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		if inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+			if ebiten.IsKeyPressed(ebiten.KeyShift) {
+				g.redo()
+			} else {
+				g.undo()
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyY) {
+			g.redo()
+		}
+	}
+}
+
+// handleToolbarClick runs the toolbar button under the cursor. It reports
+// whether the click landed on the toolbar.
+func (g *Game) handleToolbarClick(x, y int) bool {
+	for _, button := range toolbarButtons {
+		if x >= button.x && x < button.x+button.width && y >= button.y && y < button.y+toolbarButtonHeight {
+			if button.tool != "" {
+				g.setDrawMode(button.tool)
+			} else if button.action != nil {
+				button.action(g)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// handleMouseDraw paints with the current tool while the mouse is held. A
+// stroke starts when the button is pressed and ends when it is released.
+func (g *Game) handleMouseDraw() {
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.endStroke()
+		return
+	}
+	
+	x, y := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if g.handleToolbarClick(x, y) || (x >= 520 && y <= 80) {
+			return
+		}
+		g.stroke = &editStroke{}
+		g.strokeCells = make(map[Position]bool)
+	}
+	if g.stroke == nil {
+		return
+	}
+	
+	center, ok := g.cellAt(x, y)
+	if !ok {
+		return
+	}
+	for _, pos := range g.brushCells(center) {
+		if g.strokeCells[pos] {
+			continue
+		}
+		g.strokeCells[pos] = true
+		g.paintCell(pos)
+	}
+}
+
+func (g *Game) brushCells(center Position) []Position {
+	cells := make([]Position, 0)
+	r := g.brushRadius
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			x, y := center.X+dx, center.Y+dy
+			if dx*dx+dy*dy > r*r+r || x < 0 || x >= g.world.Width || y < 0 || y >= g.world.Height {
+				continue
+			}
+			cells = append(cells, Position{x, y})
+		}
+	}
+	return cells
+}
+
+func (g *Game) paintCell(pos Position) {
+	w := g.world
+	before := w.cellState(pos)
+	kind := w.Grid[pos.X][pos.Y]
+	
+	switch g.drawMode {
+	case "rabbit", "fox", "grass":
+		if kind != Empty && kind != GrassType {
+			return
+		}
+		if rand.Float64() >= g.brushDensity {
+			return
+		}
+	}
+	
+	switch g.drawMode {
+	case "rabbit":
+		if len(w.Rabbits) >= w.rabbitLimit() {
+			return
+		}
+		w.Rabbits = append(w.Rabbits, &Rabbit{
+			Animal: Animal{
+				ID:       w.newID(),
+				Position: pos,
+				Energy:   80,
+			},
+			NewBorn: 60,
+		})
+		w.Grid[pos.X][pos.Y] = RabbitType
+	
+	case "fox":
+		if len(w.Foxes) >= w.foxLimit() {
+			return
+		}
+		w.Foxes = append(w.Foxes, &Fox{
+			Animal: Animal{
+				ID:       w.newID(),
+				Position: pos,
+				Energy:   80,
+			},
+		})
+		w.Grid[pos.X][pos.Y] = FoxType
+	
+	case "grass":
+		w.Grass[pos] = &Grass{Position: pos, Amount: maxGrassAmount}
+		w.Grid[pos.X][pos.Y] = GrassType
+	
+	case "terrain":
+		if before.region == g.terrainRegion {
+			return
+		}
+		w.RegionMap[pos.X][pos.Y] = g.terrainRegion
+		w.RegionVersion++
+		g.stroke.terrain = true
+	
+	case "eraser":
+		if kind == Empty && before.grass == nil {
+			return
+		}
+		w.clearCell(pos)
+	}
+	
+	g.stroke.edits = append(g.stroke.edits, cellEdit{pos: pos, before: before, after: w.cellState(pos)})
+}
+
+func (g *Game) endStroke() {
+	if g.stroke == nil {
+		return
+	}
+	
+	stroke := g.stroke
+	g.stroke = nil
+	g.strokeCells = nil
+	if len(stroke.edits) == 0 {
+		return
+	}
+	
+	if stroke.terrain {
+		g.world.updateRegionDistances()
+	}
+	g.undoStack = append(g.undoStack, stroke)
+	if len(g.undoStack) > maxUndoSteps {
+		g.undoStack = g.undoStack[1:]
+	}
+	g.redoStack = g.redoStack[:0]
+	log.Printf("Edit: %s on %d cell(s)", g.drawMode, len(stroke.edits))
+	g.world.recordChange("Edit", fmt.Sprintf("%s on %d cell(s)", g.drawMode, len(stroke.edits)))
+}
+
+func (g *Game) undo() {
+	if len(g.undoStack) == 0 {
+		return
+	}
+	
+	stroke := g.undoStack[len(g.undoStack)-1]
+	g.undoStack = g.undoStack[:len(g.undoStack)-1]
+	for i := len(stroke.edits) - 1; i >= 0; i-- {
+		g.world.applyEdit(stroke.edits[i].pos, stroke.edits[i].after, stroke.edits[i].before)
+	}
+	if stroke.terrain {
+		g.world.updateRegionDistances()
+	}
+	
+	g.redoStack = append(g.redoStack, stroke)
+	log.Printf("Undo: %d cell(s)", len(stroke.edits))
+	g.world.recordChange("Undo", fmt.Sprintf("%d cell(s)", len(stroke.edits)))
+}
+
+func (g *Game) redo() {
+	if len(g.redoStack) == 0 {
+		return
+	}
+	
+	stroke := g.redoStack[len(g.redoStack)-1]
+	g.redoStack = g.redoStack[:len(g.redoStack)-1]
+	for _, edit := range stroke.edits {
+		g.world.applyEdit(edit.pos, edit.before, edit.after)
+	}
+	if stroke.terrain {
+		g.world.updateRegionDistances()
+	}
+	
+	g.undoStack = append(g.undoStack, stroke)
+	log.Printf("Redo: %d cell(s)", len(stroke.edits))
+	g.world.recordChange("Redo", fmt.Sprintf("%d cell(s)", len(stroke.edits)))
+}
+
+// cellState copies everything on a cell.
+func (w *World) cellState(pos Position) cellState {
+	state := cellState{region: w.RegionMap[pos.X][pos.Y]}
+	if grass, exists := w.Grass[pos]; exists {
+		copied := *grass
+		state.grass = &copied
+	}
+	if rabbit := w.findRabbitAtPosition(pos); rabbit != nil {
+		copied := *rabbit
+		state.rabbit = &copied
+	}
+	if fox := w.findFoxAtPosition(pos); fox != nil {
+		copied := *fox
+		copied.PackID = 0
+		copied.PackTarget = nil
+		state.fox = &copied
+	}
+	return state
+}
+
+// clearCell removes every entity on a cell.
+func (w *World) clearCell(pos Position) {
+	delete(w.Grass, pos)
+	for i := len(w.Rabbits) - 1; i >= 0; i-- {
+		if w.Rabbits[i].Animal.Position == pos {
+			w.removeRabbit(i)
+		}
+	}
+	for i := len(w.Foxes) - 1; i >= 0; i-- {
+		if w.Foxes[i].Animal.Position == pos {
+			w.removeFox(i)
+		}
+	}
+	w.Grid[pos.X][pos.Y] = Empty
+}
+
+// applyEdit takes a cell edited by a stroke from one recorded state to the
+// other. Only what differs between the states is changed: the grass, the
+// region, and the animals placed or erased. Placed animals are taken back
+// wherever they have moved since; erased ones come back only if their cell
+// is free of animals.
+func (w *World) applyEdit(pos Position, from, to cellState) {
+	if !sameGrass(from.grass, to.grass) {
+		delete(w.Grass, pos)
+		if to.grass != nil {
+			grass := *to.grass
+			w.Grass[pos] = &grass
+		}
+	}
+	if from.region != to.region {
+		w.RegionMap[pos.X][pos.Y] = to.region
+	}
+	
+	// The stroke placed or erased the animal whose ID differs between the states
+	fromRabbit, toRabbit := from.rabbitID(), to.rabbitID()
+	fromFox, toFox := from.foxID(), to.foxID()
+	if fromRabbit != toRabbit {
+		if i := w.rabbitIndex(fromRabbit); i >= 0 {
+			moved := w.Rabbits[i].Animal.Position
+			w.removeRabbit(i)
+			w.refreshCell(moved)
+		}
+	}
+	if fromFox != toFox {
+		if i := w.foxIndex(fromFox); i >= 0 {
+			moved := w.Foxes[i].Animal.Position
+			w.removeFox(i)
+			w.refreshCell(moved)
+		}
+	}
+	
+	// Animals get their old IDs back, so the inspector finds them again
+	free := w.findRabbitAtPosition(pos) == nil && w.findFoxAtPosition(pos) == nil
+	if toRabbit != fromRabbit && toRabbit != 0 && free && w.rabbitIndex(toRabbit) < 0 {
+		rabbit := *to.rabbit
+		w.Rabbits = append(w.Rabbits, &rabbit)
+	} else if toFox != fromFox && toFox != 0 && free && w.foxIndex(toFox) < 0 {
+		fox := *to.fox
+		w.Foxes = append(w.Foxes, &fox)
+	}
+	w.refreshCell(pos)
+}
+
+// rabbitID and foxID return the ID of the animal in a state, 0 if none.
+func (s cellState) rabbitID() int {
+	if s.rabbit == nil {
+		return 0
+	}
+	return s.rabbit.Animal.ID
+}
+
+func (s cellState) foxID() int {
+	if s.fox == nil {
+		return 0
+	}
+	return s.fox.Animal.ID
+}
+
+func sameGrass(a, b *Grass) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Amount == b.Amount
+}
+
+func (w *World) rabbitIndex(id int) int {
+	for i, rabbit := range w.Rabbits {
+		if rabbit.Animal.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *World) foxIndex(id int) int {
+	for i, fox := range w.Foxes {
+		if fox.Animal.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// refreshCell sets the grid type of a cell from what is on it.
+func (w *World) refreshCell(pos Position) {
+	switch {
+	case w.findRabbitAtPosition(pos) != nil:
+		w.Grid[pos.X][pos.Y] = RabbitType
+	case w.findFoxAtPosition(pos) != nil:
+		w.Grid[pos.X][pos.Y] = FoxType
+	case w.Grass[pos] != nil:
+		w.Grid[pos.X][pos.Y] = GrassType
+	default:
+		w.Grid[pos.X][pos.Y] = Empty
+	}
+}
+
+func (g *Game) drawToolbar(screen *ebiten.Image) {
+	g.fillRect(screen, toolbarX-4, toolbarY-4, toolbarWidth+8, toolbarHeight+8, color.RGBA{20, 20, 30, 230})
+	for _, button := range toolbarButtons {
+		buttonColor := color.RGBA{60, 60, 70, 255}
+		switch {
+		case button.tool != "" && button.tool == g.drawMode:
+			buttonColor = color.RGBA{120, 120, 60, 255}
+		case button.tool == "" && button.action == nil:
+			buttonColor = color.RGBA{35, 35, 45, 255}
+		}
+		
+		g.fillRect(screen, button.x, button.y, button.width, toolbarButtonHeight, buttonColor)
+		ebitenutil.DebugPrintAt(screen, button.label(g), button.x+3, button.y+3)
+	}
+}
+
+// drawBrush shows the cells the brush would paint under the cursor.
+func (g *Game) drawBrush(screen *ebiten.Image) {
+	center, ok := g.cellAt(ebiten.CursorPosition())
+	if !ok {
+		return
+	}
+	
+	var brushColor color.RGBA
+	switch g.drawMode {
+	case "rabbit":
+		brushColor = color.RGBA{255, 255, 255, 128}
+	case "fox":
+		brushColor = color.RGBA{255, 0, 0, 128}
+	case "grass":
+		brushColor = color.RGBA{0, 200, 0, 128}
+	case "terrain":
+		region := g.world.Regions[g.terrainRegion].Color
+		brushColor = color.RGBA{region.R, region.G, region.B, 200}
+	case "eraser":
+		brushColor = color.RGBA{80, 80, 80, 160}
+	}
+	
+	pixels := float64(g.cellSize()) * g.worldScale()
+	size := int(pixels)
+	if size < 1 {
+		size = 1
+	}
+	for _, pos := range g.brushCells(center) {
+		g.fillRect(screen, int(float64(pos.X)*pixels), int(float64(pos.Y)*pixels), size, size, brushColor)
+	}
+}
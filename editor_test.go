@@ -0,0 +1,191 @@
+package main
+
+import "testing"
+
+func newEditorGame() *Game {
+	w := NewWorld(minGridSize, minGridSize)
+	w.Grass = make(map[Position]*Grass)
+	return &Game{world: w, brushDensity: 1}
+}
+
+// paint makes a one-cell stroke with the given tool.
+func (g *Game) paint(tool string, pos Position) {
+	g.drawMode = tool
+	g.stroke = &editStroke{}
+	g.paintCell(pos)
+	g.endStroke()
+}
+
+func placeRabbit(w *World, pos Position) *Rabbit {
+	rabbit := &Rabbit{Animal: Animal{ID: w.newID(), Position: pos, Energy: 80}}
+	w.Rabbits = append(w.Rabbits, rabbit)
+	w.Grid[pos.X][pos.Y] = RabbitType
+	return rabbit
+}
+
+// walkRabbit moves a rabbit the way the simulation would.
+func walkRabbit(w *World, rabbit *Rabbit, to Position) {
+	from := rabbit.Animal.Position
+	rabbit.Animal.Position = to
+	w.refreshCell(from)
+	w.Grid[to.X][to.Y] = RabbitType
+}
+
+func TestUndoRestoresOnlyWhatTheStrokeChanged(t *testing.T) {
+	cell, away := Position{4, 4}, Position{8, 8}
+	
+	tests := []struct {
+		name  string
+		setup func(g *Game)
+		tool  string
+		after func(g *Game) // What the simulation does before the undo
+		check func(t *testing.T, g *Game)
+	}{
+		{
+			name: "grass stroke keeps a rabbit that walked onto the cell",
+			tool: "grass",
+			after: func(g *Game) {
+				placeRabbit(g.world, cell)
+			},
+			check: func(t *testing.T, g *Game) {
+				if g.world.Grass[cell] != nil {
+					t.Error("painted grass is still there")
+				}
+				if len(g.world.Rabbits) != 1 || g.world.Grid[cell.X][cell.Y] != RabbitType {
+					t.Errorf("rabbit on the cell was lost: %d rabbits, cell type %d", len(g.world.Rabbits), g.world.Grid[cell.X][cell.Y])
+				}
+			},
+		},
+		{
+			name: "terrain stroke leaves a rabbit that moved away",
+			setup: func(g *Game) {
+				placeRabbit(g.world, cell)
+				g.terrainRegion = (g.world.RegionMap[cell.X][cell.Y] + 1) % len(g.world.Regions)
+			},
+			tool: "terrain",
+			after: func(g *Game) {
+				walkRabbit(g.world, g.world.Rabbits[0], away)
+			},
+			check: func(t *testing.T, g *Game) {
+				if g.world.RegionMap[cell.X][cell.Y] == g.terrainRegion {
+					t.Error("region was not restored")
+				}
+				if len(g.world.Rabbits) != 1 || g.world.Rabbits[0].Animal.Position != away {
+					t.Errorf("rabbit snapped back or was duplicated: %d rabbits", len(g.world.Rabbits))
+				}
+				if g.world.Grid[cell.X][cell.Y] != Empty {
+					t.Errorf("cell type %d, want empty", g.world.Grid[cell.X][cell.Y])
+				}
+			},
+		},
+		{
+			name: "terrain stroke does not bring back a rabbit that died",
+			setup: func(g *Game) {
+				placeRabbit(g.world, cell)
+				g.terrainRegion = (g.world.RegionMap[cell.X][cell.Y] + 1) % len(g.world.Regions)
+			},
+			tool: "terrain",
+			after: func(g *Game) {
+				g.world.removeRabbit(0)
+			},
+			check: func(t *testing.T, g *Game) {
+				if len(g.world.Rabbits) != 0 {
+					t.Error("dead rabbit came back")
+				}
+			},
+		},
+		{
+			name: "rabbit placement is taken back after the rabbit moved",
+			tool: "rabbit",
+			after: func(g *Game) {
+				g.world.Grass[away] = &Grass{Position: away, Amount: 50}
+				walkRabbit(g.world, g.world.Rabbits[0], away)
+			},
+			check: func(t *testing.T, g *Game) {
+				if len(g.world.Rabbits) != 0 {
+					t.Error("placed rabbit is still there")
+				}
+				if g.world.Grid[away.X][away.Y] != GrassType {
+					t.Errorf("cell the rabbit left has type %d, want grass", g.world.Grid[away.X][away.Y])
+				}
+			},
+		},
+		{
+			name: "eraser brings back grass but keeps the grazing elsewhere",
+			setup: func(g *Game) {
+				g.world.Grass[cell] = &Grass{Position: cell, Amount: 70}
+				g.world.Grass[away] = &Grass{Position: away, Amount: 70}
+				g.world.Grid[cell.X][cell.Y] = GrassType
+				g.world.Grid[away.X][away.Y] = GrassType
+			},
+			tool: "eraser",
+			after: func(g *Game) {
+				g.world.Grass[away].Amount = 10
+			},
+			check: func(t *testing.T, g *Game) {
+				if grass := g.world.Grass[cell]; grass == nil || grass.Amount != 70 {
+					t.Error("erased grass was not restored")
+				}
+				if g.world.Grass[away].Amount != 10 {
+					t.Error("grass outside the stroke was changed")
+				}
+			},
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newEditorGame()
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			g.paint(tt.tool, cell)
+			if len(g.undoStack) != 1 {
+				t.Fatalf("stroke not recorded")
+			}
+			tt.after(g)
+			g.undo()
+			tt.check(t, g)
+		})
+	}
+}
+
+func TestRedoAfterUndo(t *testing.T) {
+	g := newEditorGame()
+	cell := Position{3, 3}
+	g.paint("fox", cell)
+	id := g.world.Foxes[0].Animal.ID
+	
+	g.undo()
+	if len(g.world.Foxes) != 0 || g.world.Grid[cell.X][cell.Y] != Empty {
+		t.Fatalf("undo left %d foxes, cell type %d", len(g.world.Foxes), g.world.Grid[cell.X][cell.Y])
+	}
+	g.redo()
+	if len(g.world.Foxes) != 1 || g.world.Foxes[0].Animal.ID != id || g.world.Grid[cell.X][cell.Y] != FoxType {
+		t.Fatalf("redo did not put the fox back with its ID")
+	}
+}
+
+func TestErasingFoxLeavesPack(t *testing.T) {
+	g := newEditorGame()
+	w := g.world
+	w.packHunting = true
+	for _, pos := range []Position{{2, 2}, {3, 2}, {4, 2}} {
+		w.Foxes = append(w.Foxes, &Fox{Animal: Animal{ID: w.newID(), Position: pos, Energy: 80}})
+		w.Grid[pos.X][pos.Y] = FoxType
+	}
+	w.formPacks()
+	if len(w.Packs) != 1 || len(w.Packs[0]) != 3 {
+		t.Fatalf("expected one pack of 3, got %d packs", len(w.Packs))
+	}
+	
+	g.paint("eraser", Position{3, 2})
+	if len(w.Foxes) != 2 || len(w.Packs[0]) != 2 {
+		t.Fatalf("after erasing: %d foxes, pack of %d", len(w.Foxes), len(w.Packs[0]))
+	}
+	for _, member := range w.Packs[0] {
+		if member.Animal.Position == (Position{3, 2}) {
+			t.Error("erased fox is still in its pack")
+		}
+	}
+}
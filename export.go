@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exporters for the population history and the event log. Every format gets
+// the same samples, columns and metadata; the -format flag selects which ones
+// are written.
+
+type exporter struct {
+	name  string
+	write func(export *exportRun) error
+}
+
+var exporters = []exporter{
+	{"csv", writeCSV},
+	{"json", writeJSON},
+	{"ndjson", writeNDJSON},
+	{"columnar", writeColumnar},
+	{"sqlite", writeSQLite},
+}
+
+// parseFormats turns a comma-separated list of format names into exporters.
+func parseFormats(list string) ([]*exporter, error) {
+	var formats []*exporter
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		
+		found := false
+		for i := range exporters {
+			if exporters[i].name == name {
+				formats = append(formats, &exporters[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown export format %q", name)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no export format given")
+	}
+	return formats, nil
+}
+
+func formatNames() string {
+	names := make([]string, len(exporters))
+	for i, e := range exporters {
+		names[i] = e.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// exportRun is everything an exporter needs for one export.
+type exportRun struct {
+	history      *populationHistory
+	meta         RunMetadata
+	metaFilename string
+	timestamp    string
+	columns      []exportColumn
+	events       []EventRecord
+}
+
+func (e *exportRun) dataFilename(ext string) string {
+	return fmt.Sprintf("ecosystem_data_%s.%s", e.timestamp, ext)
+}
+
+func (e *exportRun) eventsFilename(ext string) string {
+	return fmt.Sprintf("ecosystem_events_%s.%s", e.timestamp, ext)
+}
+
+type columnKind int
+
+const (
+	intColumn columnKind = iota
+	floatColumn
+	textColumn
+)
+
+// exportColumn is one column of the sample table. value returns an int, a
+// float64 or a string depending on kind.
+type exportColumn struct {
+	name  string
+	kind  columnKind
+	value func(data PopulationData) any
+}
+
+func intCol(name string, value func(data PopulationData) int) exportColumn {
+	return exportColumn{name, intColumn, func(data PopulationData) any { return value(data) }}
+}
+
+func floatCol(name string, value func(data PopulationData) float64) exportColumn {
+	return exportColumn{name, floatColumn, func(data PopulationData) any { return value(data) }}
+}
+
+// sampleColumns lists the columns of the sample table, with one group of
+// counts for each region.
+func sampleColumns(regions []Region) []exportColumn {
+	columns := []exportColumn{
+		intCol("Tick", func(data PopulationData) int { return data.Tick }),
+		intCol("Rabbits", func(data PopulationData) int { return data.Rabbits }),
+		intCol("Foxes", func(data PopulationData) int { return data.Foxes }),
+		intCol("Grass", func(data PopulationData) int { return data.Grass }),
+		{"Timestamp", textColumn, func(data PopulationData) any { return data.Time.Format(timestampFormat) }},
+	}
+	
+	for r, region := range regions {
+		count := func(data PopulationData) RegionCount {
+			if r < len(data.Regions) {
+				return data.Regions[r]
+			}
+			return RegionCount{}
+		}
+		columns = append(columns,
+			intCol(region.Name+"_Rabbits", func(data PopulationData) int { return count(data).Rabbits }),
+			intCol(region.Name+"_Foxes", func(data PopulationData) int { return count(data).Foxes }),
+			intCol(region.Name+"_Grass", func(data PopulationData) int { return count(data).Grass }),
+		)
+	}
+	
+	columns = append(columns,
+		intCol("HuntAttempts", func(data PopulationData) int { return data.HuntAttempts }),
+		intCol("HuntSuccesses", func(data PopulationData) int { return data.HuntSuccesses }),
+		intCol("GrassBiomass", func(data PopulationData) int { return data.GrassBiomass }),
+	)
+	
+	for _, species := range []struct {
+		name  string
+		stats func(data PopulationData) SpeciesStats
+	}{
+		{"Rabbit", func(data PopulationData) SpeciesStats { return data.RabbitStats }},
+		{"Fox", func(data PopulationData) SpeciesStats { return data.FoxStats }},
+	} {
+		stats := species.stats
+		columns = append(columns,
+			floatCol(species.name+"_MeanEnergy", func(data PopulationData) float64 { return stats(data).MeanEnergy }),
+			intCol(species.name+"_MinEnergy", func(data PopulationData) int { return stats(data).MinEnergy }),
+			intCol(species.name+"_MaxEnergy", func(data PopulationData) int { return stats(data).MaxEnergy }),
+			floatCol(species.name+"_MeanAge", func(data PopulationData) float64 { return stats(data).MeanAge }),
+			intCol(species.name+"_MinAge", func(data PopulationData) int { return stats(data).MinAge }),
+			intCol(species.name+"_MaxAge", func(data PopulationData) int { return stats(data).MaxAge }),
+		)
+	}
+	
+	return append(columns,
+		intCol("RabbitBirths", func(data PopulationData) int { return data.RecentEvents.RabbitBirths }),
+		intCol("FoxBirths", func(data PopulationData) int { return data.RecentEvents.FoxBirths }),
+		intCol("RabbitsEaten", func(data PopulationData) int { return data.RecentEvents.RabbitsEaten }),
+		intCol("RabbitsStarved", func(data PopulationData) int { return data.RecentEvents.RabbitsStarved }),
+		intCol("FoxesStarved", func(data PopulationData) int { return data.RecentEvents.FoxesStarved }),
+		intCol("Hunts", func(data PopulationData) int { return data.Hunts }),
+	)
+}
+
+// EventRecord is one entry of the event log: the births, deaths and hunts
+// between two samples, one record per kind, every parameter change and every
+// toggle, edit and rewind.
+type EventRecord struct {
+	Tick   int
+	Event  string // RabbitBirths, FoxBirths, RabbitsEaten, RabbitsStarved, FoxesStarved, Hunts, ParamChange or a RunChange kind
+	Count  int
+	Detail string // For parameter changes: "name old -> new", for run changes their detail
+}
+
+// eventLog builds the event log of the run, ordered by tick. Kinds with no
+// events in a sample interval are left out.
+func eventLog(history *populationHistory, meta RunMetadata) ([]EventRecord, error) {
+	var events []EventRecord
+	err := history.each(func(data PopulationData) error {
+		e := data.RecentEvents
+		for _, count := range []struct {
+			name  string
+			count int
+		}{
+			{"RabbitBirths", e.RabbitBirths},
+			{"FoxBirths", e.FoxBirths},
+			{"RabbitsEaten", e.RabbitsEaten},
+			{"RabbitsStarved", e.RabbitsStarved},
+			{"FoxesStarved", e.FoxesStarved},
+			{"Hunts", data.Hunts},
+		} {
+			if count.count > 0 {
+				events = append(events, EventRecord{Tick: data.Tick, Event: count.name, Count: count.count})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, change := range meta.ParamLog {
+		events = append(events, EventRecord{
+			Tick:   change.Tick,
+			Event:  "ParamChange",
+			Count:  1,
+			Detail: fmt.Sprintf("%s %s -> %s", change.Name, change.Old, change.New),
+		})
+	}
+	for _, change := range meta.ChangeLog {
+		events = append(events, EventRecord{Tick: change.Tick, Event: change.Kind, Count: 1, Detail: change.Detail})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Tick < events[j].Tick })
+	return events, nil
+}
+
+func exportPopulationData(history *populationHistory, world *World, formats []*exporter) {
+	if history.len() == 0 {
+		return
+	}
+	
+	meta := runMetadata(history, world)
+	export := &exportRun{
+		history:   history,
+		meta:      meta,
+		timestamp: time.Now().Format("2006-01-02_15-04-05"),
+		columns:   sampleColumns(meta.Regions),
+	}
+	
+	export.metaFilename = export.dataFilename("meta.json")
+	if err := writeMetadataJSON(export.metaFilename, export.meta); err != nil {
+		log.Printf("Error writing metadata file: %v", err)
+		export.metaFilename = "(not written)"
+	}
+	
+	events, err := eventLog(history, export.meta)
+	if err != nil {
+		log.Printf("Error reading population history: %v", err)
+		return
+	}
+	export.events = events
+	
+	for _, format := range formats {
+		if err := format.write(export); err != nil {
+			log.Printf("Error exporting %s data: %v", format.name, err)
+		}
+	}
+	log.Printf("Exported %d data points and %d events covering %d ticks", history.len(), len(events), history.last().Tick)
+	
+	maxRabbits, maxFoxes, maxGrass := 0, 0, 0
+	history.each(func(data PopulationData) error {
+		maxRabbits = max(maxRabbits, data.Rabbits)
+		maxFoxes = max(maxFoxes, data.Foxes)
+		maxGrass = max(maxGrass, data.Grass)
+		return nil
+	})
+	if history.len() > 1 {
+		log.Printf("Peak populations: Rabbits=%d, Foxes=%d, Grass=%d", maxRabbits, maxFoxes, maxGrass)
+	}
+}
+
+// createExportFile creates a file and a buffered writer for it. The returned
+// function flushes and closes the file and reports the first error.
+func createExportFile(filename string) (*bufio.Writer, func() error, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer := bufio.NewWriter(file)
+	return writer, func() error {
+		err := writer.Flush()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// metadataHeader describes the run in the lines of the CSV # header.
+func metadataHeader(meta RunMetadata, metaFilename string) []string {
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	
+	add("Ecosystem Simulation Data Export")
+	add("Program: %s %s (%s)", meta.Program, meta.Version, meta.GoVersion)
+	add("Command line: %s", strings.Join(meta.Args, " "))
+	add("Started: %s", meta.Started.Format(timestampFormat))
+	add("Generated: %s", meta.Generated.Format(timestampFormat))
+	add("Duration: %d ticks (%d data points, one every %d ticks)", meta.Ticks, meta.Samples, meta.SampleInterval)
+	add("Metadata: %s", metaFilename)
+	add("")
+	add("Simulation parameters:")
+	add("- Seed: %d", meta.Seed)
+	add("- Grid size: %dx%d", meta.GridWidth, meta.GridHeight)
+	add("- Workers: %d (tile size %d)", meta.Workers, meta.TileSize)
+	for _, name := range sortedKeys(meta.Features) {
+		add("- Feature %s: %t", name, meta.Features[name])
+	}
+	for i := range tunables {
+		t := &tunables[i]
+		add("- %s: %s", t.name, t.format(t.value(&meta.Params)))
+	}
+	for _, change := range meta.ParamLog {
+		add("- Changed at tick %d: %s %s -> %s", change.Tick, change.Name, change.Old, change.New)
+	}
+	for _, change := range meta.ChangeLog {
+		add("- %s at tick %d: %s", change.Kind, change.Tick, change.Detail)
+	}
+	for _, species := range []struct {
+		name   string
+		params SpeciesParams
+	}{{"Rabbit", meta.RabbitParams}, {"Fox", meta.FoxParams}} {
+		p := species.params
+		add("- %s metabolism: max energy %d, idle %.4f, move %.4f, chase %.4f, reproduce %.1f, digest %.4f x %d ticks",
+			species.name, p.MaxEnergy, p.IdleCost, p.MoveCost, p.ChaseCost, p.ReproduceCost, p.DigestCost, p.DigestTicks)
+		add("- %s gestation: %d ticks, litter size weights %v", species.name, p.GestationTicks, p.LitterSizes)
+	}
+	for _, region := range meta.Regions {
+		add("- Region %s: growth %d, spawn chance %.3f, move cost %.2f, seasons %v",
+			region.Name, region.GrowthRate, region.SpawnChance, region.MoveCost, region.SeasonGrowth)
+	}
+	for _, name := range sortedKeys(meta.Constants) {
+		add("- %s: %g", name, meta.Constants[name])
+	}
+	add("")
+	return lines
+}
+
+// writeCSV writes the samples with the metadata as a # comment header, and the
+// event log to a second file.
+func writeCSV(export *exportRun) error {
+	filename := export.dataFilename("csv")
+	file, done, err := createExportFile(filename)
+	if err != nil {
+		return err
+	}
+	
+	for _, line := range metadataHeader(export.meta, export.metaFilename) {
+		file.WriteString("# " + line + "\n")
+	}
+	
+	names := make([]string, len(export.columns))
+	for i, column := range export.columns {
+		names[i] = column.name
+	}
+	file.WriteString(strings.Join(names, ",") + "\n")
+	
+	fields := make([]string, len(export.columns))
+	err = export.history.each(func(data PopulationData) error {
+		for i, column := range export.columns {
+			switch value := column.value(data).(type) {
+			case int:
+				fields[i] = strconv.Itoa(value)
+			case float64:
+				fields[i] = strconv.FormatFloat(value, 'f', 2, 64)
+			case string:
+				fields[i] = value
+			}
+		}
+		_, err := file.WriteString(strings.Join(fields, ",") + "\n")
+		return err
+	})
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Population data exported to: %s", filename)
+	
+	eventsFilename := export.eventsFilename("csv")
+	file, done, err = createExportFile(eventsFilename)
+	if err != nil {
+		return err
+	}
+	// Details are free text, so the rows go through the csv writer for quoting
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Tick", "Event", "Count", "Detail"})
+	for _, event := range export.events {
+		writer.Write([]string{strconv.Itoa(event.Tick), event.Event, strconv.Itoa(event.Count), event.Detail})
+	}
+	writer.Flush()
+	err = writer.Error()
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Event log exported to: %s", eventsFilename)
+	return nil
+}
+
+// writeJSON writes a single JSON document with the metadata, the samples and
+// the event log.
+func writeJSON(export *exportRun) error {
+	filename := export.dataFilename("json")
+	file, done, err := createExportFile(filename)
+	if err != nil {
+		return err
+	}
+	
+	meta, err := json.Marshal(export.meta)
+	if err == nil {
+		file.WriteString(`{"Metadata":`)
+		file.Write(meta)
+		file.WriteString(`,"Samples":[`)
+		first := true
+		err = export.history.each(func(data PopulationData) error {
+			if !first {
+				file.WriteString(",\n")
+			}
+			first = false
+			
+			line, err := json.Marshal(data)
+			file.Write(line)
+			return err
+		})
+	}
+	if err == nil {
+		var events []byte
+		events, err = json.Marshal(export.events)
+		file.WriteString("],\n\"Events\":")
+		file.Write(events)
+		file.WriteString("}\n")
+	}
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	
+	log.Printf("JSON data exported to: %s", filename)
+	return nil
+}
+
+// writeNDJSON writes one sample per line, and the event log one event per line
+// to a second file. The metadata is in the .meta.json file.
+func writeNDJSON(export *exportRun) error {
+	filename := export.dataFilename("ndjson")
+	file, done, err := createExportFile(filename)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	err = export.history.each(func(data PopulationData) error {
+		return encoder.Encode(data)
+	})
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	
+	eventsFilename := export.eventsFilename("ndjson")
+	file, done, err = createExportFile(eventsFilename)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(file)
+	for _, event := range export.events {
+		if err = encoder.Encode(event); err != nil {
+			break
+		}
+	}
+	if closeErr := done(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	
+	log.Printf("NDJSON data exported to: %s and %s", filename, eventsFilename)
+	return nil
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunChangesReachTheEventLog(t *testing.T) {
+	g := newEditorGame()
+	g.sampleInterval = 10
+	g.history = newPopulationHistory(g.sampleInterval)
+	g.timeline = newSnapshotRing(snapshotCapacity)
+	g.timelineCursor = -1
+	g.recordSnapshot()
+	
+	for i := 0; i < 20; i++ {
+		g.step()
+	}
+	g.toggleHerding()
+	g.paint("grass", Position{2, 2})
+	g.undo()
+	g.showSnapshot(1)
+	g.step()
+	
+	want := []RunChange{
+		{20, "FeatureToggle", "Herding on"},
+		{20, "Edit", "grass on 1 cell(s)"},
+		{20, "Undo", "1 cell(s)"},
+		{10, "Rewind", "from tick 20"},
+	}
+	if len(g.world.ChangeLog) != len(want) {
+		t.Fatalf("change log %v, want %v", g.world.ChangeLog, want)
+	}
+	for i, change := range g.world.ChangeLog {
+		if change != want[i] {
+			t.Errorf("change %d is %v, want %v", i, change, want[i])
+		}
+	}
+	
+	events, err := eventLog(g.history, runMetadata(g.history, g.world))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := map[string]EventRecord{}
+	for i, event := range events {
+		if i > 0 && event.Tick < events[i-1].Tick {
+			t.Errorf("event log out of order at %d", i)
+		}
+		logged[event.Event] = event
+	}
+	for _, change := range want {
+		if event := logged[change.Kind]; event.Tick != change.Tick || event.Detail != change.Detail {
+			t.Errorf("%s logged as %+v", change.Kind, event)
+		}
+	}
+}
+
+// exportedRun is a short run with regions that are not the default ones, so
+// the region columns show which list the exporters used, and an event detail
+// that needs quoting in CSV.
+func exportedRun() (*populationHistory, *World) {
+	w := NewWorld(minGridSize, minGridSize)
+	w.Regions = []Region{{Name: "Marsh", GrowthRate: 1, SpawnChance: 0.02}, {Name: "Hill", GrowthRate: 3, SpawnChance: 0.005}}
+	w.ParamLog = []ParamChange{{Tick: 10, Name: "GrassGrowthRate", Old: "2", New: "3"}}
+	w.ChangeLog = []RunChange{
+		{Tick: 20, Kind: "FeatureToggle", Detail: "Herding on"},
+		{Tick: 20, Kind: "Edit", Detail: `wall on 2 cell(s), "north" edge`},
+	}
+	
+	history := newPopulationHistory(10)
+	start := time.Date(2025, 6, 14, 20, 43, 1, 250e6, time.UTC)
+	var events PopulationEvents
+	for i := 0; i < 3; i++ {
+		recent := PopulationEvents{RabbitBirths: 2 * i, FoxBirths: i, RabbitsEaten: i + 1, RabbitsStarved: 1, FoxesStarved: i % 2}
+		events = PopulationEvents{
+			RabbitBirths:   events.RabbitBirths + recent.RabbitBirths,
+			FoxBirths:      events.FoxBirths + recent.FoxBirths,
+			RabbitsEaten:   events.RabbitsEaten + recent.RabbitsEaten,
+			RabbitsStarved: events.RabbitsStarved + recent.RabbitsStarved,
+			FoxesStarved:   events.FoxesStarved + recent.FoxesStarved,
+		}
+		history.add(PopulationData{
+			Tick:          10 * i,
+			Time:          start.Add(time.Duration(i) * 1500 * time.Millisecond),
+			Rabbits:       30 + i,
+			Foxes:         5 - i,
+			Grass:         200 + 7*i,
+			Regions:       []RegionCount{{Rabbits: 20, Foxes: 3, Grass: 150 + i}, {Rabbits: 10 + i, Foxes: 2 - i, Grass: 50 + 6*i}},
+			HuntAttempts:  4 * i,
+			HuntSuccesses: 2 * i,
+			GrassBiomass:  9000 + 125*i,
+			RabbitStats:   SpeciesStats{MeanEnergy: 41.5 + float64(i), MinEnergy: 3, MaxEnergy: 99, MeanAge: 320.25, MinAge: 1, MaxAge: 2000 + i},
+			FoxStats:      SpeciesStats{MeanEnergy: 60.75, MinEnergy: 20 + i, MaxEnergy: 100, MeanAge: 1200.5, MinAge: 300, MaxAge: 4000},
+			Events:        events,
+			RecentEvents:  recent,
+			Hunts:         4,
+		})
+	}
+	return history, w
+}
+
+func historySamples(t *testing.T, history *populationHistory) []PopulationData {
+	t.Helper()
+	var samples []PopulationData
+	if err := history.each(func(data PopulationData) error {
+		samples = append(samples, data)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+// exportedFile returns the one file of the export matching pattern.
+func exportedFile(t *testing.T, pattern string) string {
+	t.Helper()
+	matches, err := filepath.Glob(pattern)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("files matching %s: %v %v", pattern, matches, err)
+	}
+	return matches[0]
+}
+
+func TestExportRoundTrips(t *testing.T) {
+	history, world := exportedRun()
+	samples := historySamples(t, history)
+	meta := runMetadata(history, world)
+	events, err := eventLog(history, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		format string
+		check  func(t *testing.T)
+	}{
+		{"csv", func(t *testing.T) {
+			file, err := os.Open(exportedFile(t, "ecosystem_events_*.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			records, err := csv.NewReader(file).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(events)+1 {
+				t.Fatalf("%d event rows, want %d", len(records)-1, len(events))
+			}
+			for i, event := range events {
+				want := []string{strconv.Itoa(event.Tick), event.Event, strconv.Itoa(event.Count), event.Detail}
+				if !reflect.DeepEqual(records[i+1], want) {
+					t.Errorf("event row %d: got %q, want %q", i, records[i+1], want)
+				}
+			}
+		}},
+		{"json", func(t *testing.T) {
+			var doc struct {
+				Metadata RunMetadata
+				Samples  []PopulationData
+				Events   []EventRecord
+			}
+			readJSONFile(t, exportedFile(t, "ecosystem_data_*[0-9].json"), &doc)
+			compareSamples(t, doc.Samples, samples)
+			compareEvents(t, doc.Events, events)
+			compareMetadata(t, doc.Metadata, meta)
+		}},
+		{"ndjson", func(t *testing.T) {
+			var got []PopulationData
+			readNDJSON(t, exportedFile(t, "ecosystem_data_*.ndjson"), func(decoder *json.Decoder) error {
+				var data PopulationData
+				err := decoder.Decode(&data)
+				got = append(got, data)
+				return err
+			})
+			compareSamples(t, got, samples)
+			
+			var gotEvents []EventRecord
+			readNDJSON(t, exportedFile(t, "ecosystem_events_*.ndjson"), func(decoder *json.Decoder) error {
+				var event EventRecord
+				err := decoder.Decode(&event)
+				gotEvents = append(gotEvents, event)
+				return err
+			})
+			compareEvents(t, gotEvents, events)
+		}},
+		{"columnar", func(t *testing.T) {
+			filename := exportedFile(t, "ecosystem_data_*.col")
+			file, err := os.Open(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			gotMeta, tables, err := readColumnar(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareMetadata(t, gotMeta, meta)
+			compareTable(t, tables["samples"], sampleRows(samples, meta.Regions))
+			compareTable(t, tables["events"], eventRows(events))
+			
+			run, err := loadReplayColumnar(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareSamples(t, historySamples(t, run.history), samples)
+			if run.history.interval != meta.SampleInterval {
+				t.Errorf("replay sample interval %d, want %d", run.history.interval, meta.SampleInterval)
+			}
+		}},
+		{"sqlite", func(t *testing.T) {
+			data, err := os.ReadFile(exportedFile(t, "ecosystem_data_*.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tables := readSQLiteTables(t, data)
+			compareRows(t, "samples", tables["samples"], sampleRows(samples, meta.Regions))
+			
+			wantEvents := eventRows(events)
+			for _, row := range wantEvents {
+				if row[3] == "" {
+					row[3] = nil // Empty details are stored as NULL
+				}
+			}
+			compareRows(t, "events", tables["events"], wantEvents)
+			
+			metadata := map[string]any{}
+			for _, row := range tables["metadata"] {
+				metadata[row[0].(string)] = row[1]
+			}
+			if metadata["Program"] != "ecosystem-sim" || metadata["SampleInterval"] != "10" {
+				t.Errorf("metadata table: Program %v, SampleInterval %v", metadata["Program"], metadata["SampleInterval"])
+			}
+			var regions []Region
+			if err := json.Unmarshal([]byte(metadata["Regions"].(string)), &regions); err != nil || len(regions) != 2 || regions[1].Name != "Hill" {
+				t.Errorf("metadata regions %v: %v", metadata["Regions"], err)
+			}
+		}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			formats, err := parseFormats(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exportPopulationData(history, world, formats)
+			tt.check(t)
+		})
+	}
+}
+
+func TestReadColumnarRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"other format", "SQLite format 3\x00"},
+		{"truncated metadata", columnarMagic + "\x10\x00\x00\x00{}"},
+		{"truncated table", columnarMagic + "\x02\x00\x00\x00{}\x01\x00\x00\x00\x07\x00samp"},
+	}
+	for _, tt := range tests {
+		if _, _, err := readColumnar(strings.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+// sampleRows is the sample table the typed formats should hold: ints as
+// int64, floats and text as they are.
+func sampleRows(samples []PopulationData, regions []Region) [][]any {
+	columns := sampleColumns(regions)
+	rows := make([][]any, len(samples))
+	for i, data := range samples {
+		for _, column := range columns {
+			rows[i] = append(rows[i], typedValue(column.value(data)))
+		}
+	}
+	return rows
+}
+
+func eventRows(events []EventRecord) [][]any {
+	rows := make([][]any, len(events))
+	for i, event := range events {
+		rows[i] = []any{int64(event.Tick), event.Event, int64(event.Count), event.Detail}
+	}
+	return rows
+}
+
+func typedValue(value any) any {
+	if n, ok := value.(int); ok {
+		return int64(n)
+	}
+	return value
+}
+
+func compareTable(t *testing.T, table *columnarTable, want [][]any) {
+	t.Helper()
+	if table == nil {
+		t.Fatal("table missing")
+	}
+	rows := make([][]any, table.rows)
+	for _, column := range table.columns {
+		for i := range rows {
+			switch column.kind {
+			case intColumn:
+				rows[i] = append(rows[i], column.ints[i])
+			case floatColumn:
+				rows[i] = append(rows[i], column.floats[i])
+			default:
+				rows[i] = append(rows[i], column.texts[i])
+			}
+		}
+	}
+	compareRows(t, table.name, rows, want)
+}
+
+func compareRows(t *testing.T, name string, got, want [][]any) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d rows, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%s row %d:\n got %v\nwant %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func compareSamples(t *testing.T, got, want []PopulationData) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Time.Equal(w.Time) {
+			t.Errorf("sample %d time %v, want %v", i, g.Time, w.Time)
+		}
+		g.Time, w.Time = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("sample %d:\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
+
+func compareEvents(t *testing.T, got, want []EventRecord) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func compareMetadata(t *testing.T, got, want RunMetadata) {
+	t.Helper()
+	if !got.Started.Equal(want.Started) {
+		t.Errorf("metadata started %v, want %v", got.Started, want.Started)
+	}
+	got.Started, want.Started = time.Time{}, time.Time{}
+	got.Generated, want.Generated = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func readJSONFile(t *testing.T, filename string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readNDJSON(t *testing.T, filename string, decode func(decoder *json.Decoder) error) {
+	t.Helper()
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		if err := decode(decoder); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
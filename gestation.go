@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+)
+
+// With gestation on, mating makes the mother pregnant for the species'
+// gestation period and she then gives birth to a whole litter, its size drawn
+// from the species' litter size distribution. Babies are placed on the free
+// cells closest to the mother, searching outwards when her neighbours are full.
+
+// drawLitterSize picks a litter size from weights, where weights[i] is the
+// relative chance of a litter of i+1.
+func drawLitterSize(weights []float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total <= 0 {
+		return 1
+	}
+	
+	roll := rand.Float64() * total
+	for i, weight := range weights {
+		roll -= weight
+		if roll < 0 {
+			return i + 1
+		}
+	}
+	return len(weights)
+}
+
+// findBirthPositions returns up to count empty cells around pos, nearest ring
+// first, looking no further than birthSearchRadius.
+func (w *World) findBirthPositions(pos Position, count int) []Position {
+	found := make([]Position, 0, count)
+	
+	for radius := 1; radius <= birthSearchRadius && len(found) < count; radius++ {
+		ring := make([]Position, 0)
+		for dx := -radius; dx <= radius; dx++ {
+			for dy := -radius; dy <= radius; dy++ {
+				if abs(dx) != radius && abs(dy) != radius {
+					continue
+				}
+				
+				x := pos.X + dx
+				y := pos.Y + dy
+				if x >= 0 && x < w.Width && y >= 0 && y < w.Height && w.Grid[x][y] == Empty {
+					ring = append(ring, Position{x, y})
+				}
+			}
+		}
+		
+		rand.Shuffle(len(ring), func(i, j int) { ring[i], ring[j] = ring[j], ring[i] })
+		for _, cell := range ring {
+			if len(found) == count {
+				break
+			}
+			found = append(found, cell)
+		}
+	}
+	
+	return found
+}
+
+// conceive makes the mother pregnant. It returns false if she already is.
+func conceive(mother *Animal, params *SpeciesParams) bool {
+	if mother.Pregnant > 0 {
+		return false
+	}
+	
+	mother.Pregnant = params.GestationTicks
+	mother.Litter = drawLitterSize(params.LitterSizes)
+	return true
+}
+
+// advancePregnancy counts down the gestation and reports whether the mother
+// gives birth this tick.
+func advancePregnancy(mother *Animal) bool {
+	if mother.Pregnant == 0 {
+		return false
+	}
+	
+	mother.Pregnant--
+	return mother.Pregnant == 0
+}
+
+func (w *World) birthRabbits(mother *Rabbit, count int) int {
+	room := w.rabbitLimit() - len(w.Rabbits)
+	if count > room {
+		count = room
+	}
+	if count <= 0 {
+		return 0
+	}
+	
+	positions := w.findBirthPositions(mother.Animal.Position, count)
+	for _, pos := range positions {
+		baby := &Rabbit{
+			Animal: Animal{
+				ID:          w.newID(),
+				Position:    pos,
+				Energy:      60,
+				ReproduceCD: w.Params.ReproductionCooldown,
+				Age:         0,
+			},
+			NewBorn: 180, // 30 seconds
+		}
+		
+		w.Rabbits = append(w.Rabbits, baby)
+		w.Grid[pos.X][pos.Y] = RabbitType
+	}
+	
+	w.Events.RabbitBirths += len(positions)
+	if len(positions) > 0 {
+		log.Printf("%d rabbit(s) born near (%d,%d)! Total rabbits: %d", len(positions), mother.Animal.Position.X, mother.Animal.Position.Y, len(w.Rabbits))
+	}
+	return len(positions)
+}
+
+func (w *World) birthFoxes(mother *Fox, count int) int {
+	room := w.foxLimit() - len(w.Foxes)
+	if count > room {
+		count = room
+	}
+	if count <= 0 {
+		return 0
+	}
+	
+	positions := w.findBirthPositions(mother.Animal.Position, count)
+	for _, pos := range positions {
+		baby := &Fox{
+			Animal: Animal{
+				ID:          w.newID(),
+				Position:    pos,
+				Energy:      60,
+				ReproduceCD: w.Params.ReproductionCooldown,
+				Age:         0,
+			},
+		}
+		
+		w.Foxes = append(w.Foxes, baby)
+		w.Grid[pos.X][pos.Y] = FoxType
+	}
+	
+	w.Events.FoxBirths += len(positions)
+	if len(positions) > 0 {
+		log.Printf("%d fox(es) born near (%d,%d)! Total foxes: %d", len(positions), mother.Animal.Position.X, mother.Animal.Position.Y, len(w.Foxes))
+	}
+	return len(positions)
+}
+
+func (w *World) countPregnant() (rabbits, foxes int) {
+	for _, rabbit := range w.Rabbits {
+		if rabbit.Animal.Pregnant > 0 {
+			rabbits++
+		}
+	}
+	for _, fox := range w.Foxes {
+		if fox.Animal.Pregnant > 0 {
+			foxes++
+		}
+	}
+	return rabbits, foxes
+}
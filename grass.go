@@ -1,35 +1,58 @@
 package main
 
-import "math/rand"
+import (
+	"math"
+	"math/rand"
+)
 
 type Grass struct {
 	Position
 	Amount int // 0-100, where 100 is fully grown
 }
 
+// updateGrass grows the grass in grid order, not map order, so the random
+// numbers it draws, and so the run, depend only on the seed.
 func (w *World) updateGrass() {
-	for _, grass := range w.Grass {
-		if grass.Amount < maxGrassAmount {
-			grass.Amount += grassGrowthRate
-			if grass.Amount > maxGrassAmount {
-				grass.Amount = maxGrassAmount
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			if grass, exists := w.Grass[Position{x, y}]; exists {
+				w.growGrass(grass, sharedRand)
 			}
 		}
 	}
 	
-	for attempts := 0; attempts < 10; attempts++ {
-		x := rand.Intn(gridWidth)
-		y := rand.Intn(gridHeight)
+	w.spawnGrass()
+}
+
+func (w *World) growGrass(grass *Grass, rng *rand.Rand) {
+	if grass.Amount < maxGrassAmount {
+		grass.Amount += w.grassGrowthAt(grass.Position, rng)
+		if grass.Amount > maxGrassAmount {
+			grass.Amount = maxGrassAmount
+		}
+	}
+}
+
+func (w *World) spawnGrass() {
+	for attempts := 0; attempts < w.grassSpawnAttempts(); attempts++ {
+		x := rand.Intn(w.Width)
+		y := rand.Intn(w.Height)
 		
 		if w.Grid[x][y] == Empty {
-			if rand.Float64() < grassSpawnChance {
-				pos := Position{x, y}
+			pos := Position{x, y}
+			if rand.Float64() < w.grassSpawnChanceAt(pos) {
 				w.Grass[pos] = &Grass{
 					Position: pos,
-					Amount:   grassGrowthRate,
+					Amount:   w.Params.GrassGrowthRate,
 				}
 				w.Grid[x][y] = GrassType
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+// grassSpawnAttempts scales the cells tried each tick with the grid area, so
+// new grass appears at the same density on any grid size.
+func (w *World) grassSpawnAttempts() int {
+	area := float64(w.Width * w.Height)
+	return max(1, int(math.Round(grassSpawnAttempts*area/(gridWidth*gridHeight))))
+}
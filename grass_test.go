@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestGrassSpawnAttemptsScaleWithArea(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          int
+	}{
+		{gridWidth, gridHeight, grassSpawnAttempts},
+		{2 * gridWidth, 2 * gridHeight, 4 * grassSpawnAttempts},
+		{800, 600, 100 * grassSpawnAttempts},
+		{minGridSize, minGridSize, 1},
+	}
+	
+	for _, tt := range tests {
+		w := &World{Width: tt.width, Height: tt.height}
+		if got := w.grassSpawnAttempts(); got != tt.want {
+			t.Errorf("%dx%d grid: %d attempts, want %d", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
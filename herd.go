@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Boids-style herding for rabbits. Every candidate move is scored by how well
+// it keeps the rabbit with its herd (cohesion), away from crowding
+// (separation), heading the same way as its neighbours (alignment), towards
+// grass (foraging) and away from foxes (fleeing). Rabbits in a herd also
+// share vigilance: a fox spotted by any herd mate alarms the whole group, and
+// bigger herds spot foxes from further away.
+
+type vector struct {
+	X, Y float64
+}
+
+func (v vector) normalized() vector {
+	length := math.Hypot(v.X, v.Y)
+	if length == 0 {
+		return vector{}
+	}
+	return vector{v.X / length, v.Y / length}
+}
+
+func (v vector) dot(o vector) float64 {
+	return v.X*o.X + v.Y*o.Y
+}
+
+func (w *World) herdMates(rabbit *Rabbit) []*Rabbit {
+	mates := make([]*Rabbit, 0)
+	for _, other := range w.Rabbits {
+		if other != rabbit && chebyshev(other.Animal.Position, rabbit.Animal.Position) <= herdRadius {
+			mates = append(mates, other)
+		}
+	}
+	return mates
+}
+
+// rabbitVigilanceRange is how far a rabbit spots foxes given the size of its herd.
+func rabbitVigilanceRange(mates int) int {
+	bonus := mates / herdVigilanceStep
+	if bonus > maxVigilanceBonus {
+		bonus = maxVigilanceBonus
+	}
+	return rabbitVisionRange + bonus
+}
+
+// fleeDirection points away from every fox seen by the rabbit or its herd mates.
+func (w *World) fleeDirection(rabbit *Rabbit, mates []*Rabbit) vector {
+	watchers := append([]*Rabbit{rabbit}, mates...)
+	visionRange := rabbitVigilanceRange(len(mates))
+	
+	var away vector
+	for _, fox := range w.Foxes {
+		for _, watcher := range watchers {
+			if chebyshev(watcher.Animal.Position, fox.Animal.Position) <= visionRange {
+				dx := float64(rabbit.Animal.Position.X - fox.Animal.Position.X)
+				dy := float64(rabbit.Animal.Position.Y - fox.Animal.Position.Y)
+				distance := math.Max(math.Hypot(dx, dy), 1)
+				away.X += dx / (distance * distance)
+				away.Y += dy / (distance * distance)
+				break
+			}
+		}
+	}
+	return away.normalized()
+}
+
+func (w *World) herdMove(rabbit *Rabbit, validMoves []Position, rng *rand.Rand) Position {
+	pos := rabbit.Animal.Position
+	mates := w.herdMates(rabbit)
+	
+	var toCentre, heading vector
+	if len(mates) > 0 {
+		for _, mate := range mates {
+			toCentre.X += float64(mate.Animal.Position.X - pos.X)
+			toCentre.Y += float64(mate.Animal.Position.Y - pos.Y)
+			heading.X += float64(mate.Heading.X)
+			heading.Y += float64(mate.Heading.Y)
+		}
+		toCentre = toCentre.normalized()
+		heading = heading.normalized()
+	}
+	
+	flee := w.fleeDirection(rabbit, mates)
+	
+	best := validMoves[0]
+	bestScore := math.Inf(-1)
+	for _, move := range validMoves {
+		dir := vector{float64(move.X - pos.X), float64(move.Y - pos.Y)}.normalized()
+		
+		crowd := 0
+		for _, adjacent := range w.getAdjacentPositions(move) {
+			if adjacent != pos && w.Grid[adjacent.X][adjacent.Y] == RabbitType {
+				crowd++
+			}
+		}
+		
+		food := 0.0
+		if grass, exists := w.Grass[move]; exists && grass.Amount >= w.Params.MinGrassToEat {
+			food = float64(grass.Amount) / maxGrassAmount
+		}
+		
+		score := cohesionWeight*dir.dot(toCentre) +
+			alignmentWeight*dir.dot(heading) -
+			separationWeight*float64(crowd)/8 +
+			forageWeight*food +
+			fleeWeight*dir.dot(flee) +
+			herdNoise*rng.Float64()
+		
+		if score > bestScore {
+			bestScore = score
+			best = move
+		}
+	}
+	
+	return best
+}
+
+// averageHerdSize is the mean number of herd mates per rabbit, shown in the HUD.
+func (w *World) averageHerdSize() float64 {
+	if len(w.Rabbits) == 0 {
+		return 0
+	}
+	
+	total := 0
+	for _, rabbit := range w.Rabbits {
+		total += len(w.herdMates(rabbit))
+	}
+	return float64(total) / float64(len(w.Rabbits))
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Population history of the whole run. The most recent historyMemoryPoints
+// samples are kept in memory; older ones are spilled to a temporary file, one
+// JSON line per sample. For drawing, samples are also averaged into levels of
+// 2, 4, 8, ... samples per point and the finest level that fits the chart is
+// used, so long runs draw as fast as short ones.
+
+type populationHistory struct {
+	interval int       // Ticks between samples
+	started  time.Time // Wall-clock start of the run
+	
+	recent    []PopulationData // Samples still in memory, oldest first
+	spill     *os.File
+	spillSize int64
+	offsets   []int64 // Start of each spilled sample in the spill file
+	
+	levels []*historyLevel // levels[k] averages 2^(k+1) samples per entry
+	
+	version      int // Changes whenever samples are added or removed
+	cache        []PopulationData
+	cacheLimit   int
+	cacheVersion int
+}
+
+type historyLevel struct {
+	entries []PopulationData
+	dropped bool            // Too many entries to be drawn; no longer kept
+	carry   *PopulationData // First half of the next entry
+}
+
+func newPopulationHistory(interval int) *populationHistory {
+	return &populationHistory{interval: interval, started: time.Now(), cacheVersion: -1}
+}
+
+func (h *populationHistory) len() int {
+	return len(h.offsets) + len(h.recent)
+}
+
+// last returns the most recent sample. The history must not be empty.
+func (h *populationHistory) last() PopulationData {
+	return h.recent[len(h.recent)-1]
+}
+
+func (h *populationHistory) add(data PopulationData) {
+	h.recent = append(h.recent, data)
+	h.addToLevel(0, data)
+	h.version++
+	
+	if len(h.recent) > historyMemoryPoints {
+		h.spillOldest(len(h.recent) - historyMemoryPoints/2)
+	}
+}
+
+func (h *populationHistory) addToLevel(k int, data PopulationData) {
+	if k == len(h.levels) {
+		h.levels = append(h.levels, &historyLevel{})
+	}
+	
+	level := h.levels[k]
+	if level.carry == nil {
+		level.carry = &data
+		return
+	}
+	
+	merged := averageSamples(*level.carry, data)
+	level.carry = nil
+	if !level.dropped {
+		level.entries = append(level.entries, merged)
+		if len(level.entries) > historyDisplayPoints {
+			level.entries = nil
+			level.dropped = true
+		}
+	}
+	h.addToLevel(k+1, merged)
+}
+
+// averageSamples merges two consecutive samples. The tick is that of the
+// first one and the cumulative counters are those of the second.
+func averageSamples(a, b PopulationData) PopulationData {
+	merged := PopulationData{
+		Tick:          a.Tick,
+		Time:          a.Time,
+		Rabbits:       (a.Rabbits + b.Rabbits) / 2,
+		Foxes:         (a.Foxes + b.Foxes) / 2,
+		Grass:         (a.Grass + b.Grass) / 2,
+		HuntAttempts:  b.HuntAttempts,
+		HuntSuccesses: b.HuntSuccesses,
+		
+		GrassBiomass: (a.GrassBiomass + b.GrassBiomass) / 2,
+		RabbitStats:  averageStats(a.RabbitStats, b.RabbitStats),
+		FoxStats:     averageStats(a.FoxStats, b.FoxStats),
+		Events:       b.Events,
+		RecentEvents: averageEvents(a.RecentEvents, b.RecentEvents),
+		Hunts:        (a.Hunts + b.Hunts) / 2,
+	}
+	
+	if len(a.Regions) == len(b.Regions) {
+		merged.Regions = make([]RegionCount, len(a.Regions))
+		for r := range a.Regions {
+			merged.Regions[r] = RegionCount{
+				Rabbits: (a.Regions[r].Rabbits + b.Regions[r].Rabbits) / 2,
+				Foxes:   (a.Regions[r].Foxes + b.Regions[r].Foxes) / 2,
+				Grass:   (a.Regions[r].Grass + b.Regions[r].Grass) / 2,
+			}
+		}
+	}
+	return merged
+}
+
+// downsampled returns the history with at most limit points for drawing,
+// always ending with the latest sample. The result must not be modified.
+func (h *populationHistory) downsampled(limit int) []PopulationData {
+	if len(h.offsets) == 0 && len(h.recent) <= limit {
+		return h.recent
+	}
+	if h.cacheVersion == h.version && h.cacheLimit == limit {
+		return h.cache
+	}
+	
+	h.cache = nil
+	for _, level := range h.levels {
+		if !level.dropped && len(level.entries) < limit {
+			h.cache = append(append(h.cache, level.entries...), h.last())
+			break
+		}
+	}
+	h.cacheLimit = limit
+	h.cacheVersion = h.version
+	return h.cache
+}
+
+// spillOldest moves the oldest count samples from memory to the spill file.
+// If the file cannot be written they stay in memory.
+func (h *populationHistory) spillOldest(count int) {
+	if h.spill == nil {
+		file, err := os.CreateTemp("", "ecosystem_history_*.jsonl")
+		if err != nil {
+			log.Printf("Error creating history spill file, keeping history in memory: %v", err)
+			return
+		}
+		h.spill = file
+	}
+	
+	writer := bufio.NewWriter(h.spill)
+	offsets := make([]int64, 0, count)
+	size := h.spillSize
+	for _, data := range h.recent[:count] {
+		line, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Error encoding history sample: %v", err)
+			return
+		}
+		offsets = append(offsets, size)
+		writer.Write(line)
+		writer.WriteByte('\n')
+		size += int64(len(line)) + 1
+	}
+	if err := writer.Flush(); err != nil {
+		log.Printf("Error writing history spill file, keeping history in memory: %v", err)
+		h.spill.Truncate(h.spillSize)
+		h.spill.Seek(h.spillSize, io.SeekStart)
+		return
+	}
+	
+	h.offsets = append(h.offsets, offsets...)
+	h.spillSize = size
+	h.recent = append([]PopulationData(nil), h.recent[count:]...)
+}
+
+// readSpill decodes the spilled samples from offset from to the end of the
+// spill file.
+func (h *populationHistory) readSpill(from int64, fn func(data PopulationData) error) error {
+	decoder := json.NewDecoder(bufio.NewReader(io.NewSectionReader(h.spill, from, h.spillSize-from)))
+	for decoder.More() {
+		var data PopulationData
+		if err := decoder.Decode(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// each calls fn for every sample of the run, oldest first.
+func (h *populationHistory) each(fn func(data PopulationData) error) error {
+	if h.spill != nil {
+		if err := h.readSpill(0, fn); err != nil {
+			return err
+		}
+	}
+	for _, data := range h.recent {
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateAfter drops the samples taken after the given tick. Spilled samples
+// are read back into memory as needed, and the drawing levels are rebuilt.
+func (h *populationHistory) truncateAfter(tick int) {
+	removed := false
+	for h.len() > 0 {
+		if len(h.recent) == 0 && !h.unspill(historyMemoryPoints/2) {
+			break
+		}
+		if h.last().Tick <= tick {
+			break
+		}
+		h.recent = h.recent[:len(h.recent)-1]
+		removed = true
+	}
+	if !removed {
+		return
+	}
+	
+	h.levels = nil
+	h.version++
+	err := h.each(func(data PopulationData) error {
+		h.addToLevel(0, data)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading history spill file: %v", err)
+	}
+}
+
+// unspill reads the last count spilled samples back into memory.
+func (h *populationHistory) unspill(count int) bool {
+	if len(h.offsets) == 0 {
+		return false
+	}
+	if count > len(h.offsets) {
+		count = len(h.offsets)
+	}
+	
+	from := h.offsets[len(h.offsets)-count]
+	var loaded []PopulationData
+	err := h.readSpill(from, func(data PopulationData) error {
+		loaded = append(loaded, data)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading history spill file: %v", err)
+		return false
+	}
+	
+	h.recent = append(loaded, h.recent...)
+	h.offsets = h.offsets[:len(h.offsets)-count]
+	h.spillSize = from
+	h.spill.Truncate(from)
+	h.spill.Seek(from, io.SeekStart)
+	return true
+}
+
+// close removes the spill file.
+func (h *populationHistory) close() {
+	if h.spill == nil {
+		return
+	}
+	
+	h.spill.Close()
+	os.Remove(h.spill.Name())
+	h.spill = nil
+}
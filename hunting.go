@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+)
+
+// A fox that reaches a rabbit only catches it with some probability. Well fed
+// foxes and exhausted rabbits make a catch more likely, young rabbits are
+// easier prey and old foxes slower hunters. With terrain cover on, tall grass
+// and forest help the rabbit hide. A failed attempt costs the fox energy and
+// the rabbit gets away to a neighbouring cell.
+
+func (w *World) catchProbability(fox *Fox, rabbit *Rabbit) float64 {
+	chance := catchBaseChance
+	
+	foxCondition := float64(fox.Animal.Energy) / float64(w.FoxParams.MaxEnergy)
+	rabbitCondition := float64(rabbit.Animal.Energy) / float64(w.RabbitParams.MaxEnergy)
+	chance += catchEnergyWeight * (foxCondition - rabbitCondition)
+	
+	if rabbit.Animal.Age < youngAge {
+		chance += catchAgeBonus
+	}
+	if fox.Animal.Age > elderAge {
+		chance -= catchAgeBonus
+	}
+	
+	if w.terrainCover {
+		pos := rabbit.Animal.Position
+		chance -= grassCoverWeight * w.grassCover(pos)
+		if w.regionsEnabled && w.RegionMap[pos.X][pos.Y] == ForestRegion {
+			chance -= forestCover
+		}
+	}
+	
+	if chance < minCatchChance {
+		chance = minCatchChance
+	}
+	if chance > maxCatchChance {
+		chance = maxCatchChance
+	}
+	return chance
+}
+
+// grassCover is the mean grass amount around pos, from 0 to 1. The rabbit's
+// own cell is usually grazed bare, so the surrounding cells count as well.
+func (w *World) grassCover(pos Position) float64 {
+	cells := append(w.getAdjacentPositions(pos), pos)
+	total := 0
+	for _, cell := range cells {
+		if grass, exists := w.Grass[cell]; exists {
+			total += grass.Amount
+		}
+	}
+	return float64(total) / float64(len(cells)*maxGrassAmount)
+}
+
+// rabbitEscape moves the rabbit to the free neighbouring cell furthest from
+// the fox. It returns false when the rabbit is cornered.
+func (w *World) rabbitEscape(rabbit *Rabbit, fox *Fox) bool {
+	var escape Position
+	bestDistance := -1
+	for _, pos := range w.getAdjacentPositions(rabbit.Animal.Position) {
+		cellType := w.Grid[pos.X][pos.Y]
+		if cellType != Empty && cellType != GrassType {
+			continue
+		}
+		
+		distance := abs(pos.X-fox.Animal.Position.X) + abs(pos.Y-fox.Animal.Position.Y)
+		if distance > bestDistance {
+			bestDistance = distance
+			escape = pos
+		}
+	}
+	
+	if bestDistance < 0 {
+		return false
+	}
+	
+	rabbit.Heading = Position{escape.X - rabbit.Animal.Position.X, escape.Y - rabbit.Animal.Position.Y}
+	rabbit.Animal.Position = escape
+	w.Grid[escape.X][escape.Y] = RabbitType
+	return true
+}
+
+// attemptCatch resolves a fox reaching a rabbit and reports whether the
+// rabbit was caught.
+func (w *World) attemptCatch(fox *Fox, rabbit *Rabbit) bool {
+	w.HuntAttempts++
+	
+	if rand.Float64() < w.catchProbability(fox, rabbit) || !w.rabbitEscape(rabbit, fox) {
+		w.HuntSuccesses++
+		return true
+	}
+	
+	spendEnergy(&fox.Animal, huntFailCost)
+	log.Printf("Rabbit escaped fox at (%d,%d)", fox.Animal.Position.X, fox.Animal.Position.Y)
+	return false
+}
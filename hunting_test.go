@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestGrassCoverLowersCatchChance(t *testing.T) {
+	pos := Position{5, 5}
+	
+	tests := []struct {
+		name  string
+		grass map[Position]int
+		cover bool
+		want  float64 // Expected drop in catch probability against bare ground
+	}{
+		{"bare ground", nil, true, 0},
+		{"grazed cell in a full meadow", meadow(pos, 0), true, grassCoverWeight * 8 / 9},
+		{"full meadow", meadow(pos, maxGrassAmount), true, grassCoverWeight},
+		{"half meadow", halfMeadow(pos), true, grassCoverWeight / 2},
+		{"full meadow with cover off", meadow(pos, maxGrassAmount), false, 0},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(minGridSize, minGridSize)
+			w.regionsEnabled = false
+			w.terrainCover = tt.cover
+			w.Grass = make(map[Position]*Grass)
+			fox := &Fox{Animal: Animal{Position: Position{pos.X + 1, pos.Y}, Energy: 50, Age: 1000}}
+			rabbit := &Rabbit{Animal: Animal{Position: pos, Energy: 50, Age: 1000}}
+			bare := w.catchProbability(fox, rabbit)
+			
+			for cell, amount := range tt.grass {
+				w.Grass[cell] = &Grass{Position: cell, Amount: amount}
+			}
+			got := bare - w.catchProbability(fox, rabbit)
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("cover lowered the catch chance by %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+// meadow covers the cells around pos with full grass and pos itself with the
+// given amount.
+func meadow(pos Position, centre int) map[Position]int {
+	grass := map[Position]int{pos: centre}
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if dx != 0 || dy != 0 {
+				grass[Position{pos.X + dx, pos.Y + dy}] = maxGrassAmount
+			}
+		}
+	}
+	return grass
+}
+
+func halfMeadow(pos Position) map[Position]int {
+	grass := meadow(pos, maxGrassAmount)
+	for cell := range grass {
+		grass[cell] = maxGrassAmount / 2
+	}
+	return grass
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Entity inspector. Clicking a cell with no draw mode selects the fox, rabbit
+// or grass on it. Animals are tracked by ID, so the selection follows them as
+// they move and survives rewinding the timeline. The panel shows the current
+// state and a short history of the selected entity.
+
+type selection struct {
+	kind EntityType // Empty when nothing is selected
+	id   int        // Animal ID for rabbits and foxes
+	cell Position   // Cell of the selected grass
+	gone bool       // The animal has died (or does not exist at the shown tick)
+	seen int        // Tick at which the animal was last seen alive
+}
+
+type selectionSample struct {
+	Tick     int
+	Value    int // Energy of an animal, amount of grass
+	Position Position
+}
+
+func (g *Game) handleInspectClick() {
+	x, y := ebiten.CursorPosition()
+	if x >= 520 && y <= 80 {
+		return // Control buttons
+	}
+	if g.selected.kind != Empty && x >= inspectPanelX && y >= inspectPanelY && y < inspectPanelY+inspectPanelHeight {
+		return
+	}
+	
+	pos, ok := g.cellAt(x, y)
+	if !ok {
+		return
+	}
+	
+	previous := g.selected
+	g.selected = selection{}
+	if rabbit := g.world.findRabbitAtPosition(pos); rabbit != nil {
+		g.selected = selection{kind: RabbitType, id: rabbit.Animal.ID}
+	} else if fox := g.world.findFoxAtPosition(pos); fox != nil {
+		g.selected = selection{kind: FoxType, id: fox.Animal.ID}
+	} else if _, exists := g.world.Grass[pos]; exists {
+		g.selected = selection{kind: GrassType, cell: pos}
+	}
+	
+	if g.selected != previous {
+		g.selectionHistory = g.selectionHistory[:0]
+		g.sampleSelection()
+	}
+}
+
+func (g *Game) handleInspectInput() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.selected = selection{}
+	}
+}
+
+// selectedAnimal returns the selected rabbit or fox, or nil if it has died.
+func (g *Game) selectedAnimal() (*Animal, *Rabbit, *Fox) {
+	switch g.selected.kind {
+	case RabbitType:
+		if rabbit := g.world.findRabbitByID(g.selected.id); rabbit != nil {
+			return &rabbit.Animal, rabbit, nil
+		}
+	case FoxType:
+		if fox := g.world.findFoxByID(g.selected.id); fox != nil {
+			return &fox.Animal, nil, fox
+		}
+	}
+	return nil, nil, nil
+}
+
+// sampleSelection adds the selected entity's current state to its history.
+func (g *Game) sampleSelection() {
+	if g.selected.kind == Empty {
+		return
+	}
+	
+	sample := selectionSample{Tick: g.world.Tick}
+	if g.selected.kind == GrassType {
+		grass, exists := g.world.Grass[g.selected.cell]
+		if exists {
+			sample.Value = grass.Amount
+		}
+		sample.Position = g.selected.cell
+	} else {
+		animal, _, _ := g.selectedAnimal()
+		if animal == nil {
+			g.selected.gone = true
+			return
+		}
+		g.selected.gone = false
+		g.selected.seen = g.world.Tick
+		sample.Value = animal.Energy
+		sample.Position = animal.Position
+	}
+	
+	for len(g.selectionHistory) > 0 && g.selectionHistory[len(g.selectionHistory)-1].Tick >= sample.Tick {
+		g.selectionHistory = g.selectionHistory[:len(g.selectionHistory)-1]
+	}
+	g.selectionHistory = append(g.selectionHistory, sample)
+	if len(g.selectionHistory) > inspectHistoryLength {
+		g.selectionHistory = g.selectionHistory[1:]
+	}
+}
+
+// drawSelection outlines the selected cell in the world layer.
+func (g *Game) drawSelection(screen *ebiten.Image) {
+	if g.selected.kind == Empty || g.selected.gone {
+		return
+	}
+	
+	pos := g.selected.cell
+	if animal, _, _ := g.selectedAnimal(); animal != nil {
+		pos = animal.Position
+	}
+	
+	size := g.cellSize()
+	x, y := pos.X*size, pos.Y*size
+	outline := color.RGBA{0, 255, 255, 255}
+	g.fillRect(screen, x-1, y-1, size+2, 1, outline)
+	g.fillRect(screen, x-1, y+size, size+2, 1, outline)
+	g.fillRect(screen, x-1, y-1, 1, size+2, outline)
+	g.fillRect(screen, x+size, y-1, 1, size+2, outline)
+}
+
+func (g *Game) drawInspectPanel(screen *ebiten.Image) {
+	if g.selected.kind == Empty {
+		return
+	}
+	
+	g.fillRect(screen, inspectPanelX, inspectPanelY, inspectPanelWidth, inspectPanelHeight, color.RGBA{20, 20, 30, 220})
+	ebitenutil.DebugPrintAt(screen, g.inspectText(), inspectPanelX+6, inspectPanelY+4)
+	
+	// Energy (or grass amount) over the recorded history
+	chartY := inspectPanelY + inspectPanelHeight - 50
+	chartWidth := inspectPanelWidth - 12
+	g.fillRect(screen, inspectPanelX+6, chartY, chartWidth, 40, color.RGBA{40, 40, 50, 255})
+	maxValue := 100
+	switch g.selected.kind {
+	case RabbitType:
+		maxValue = g.world.RabbitParams.MaxEnergy
+	case FoxType:
+		maxValue = g.world.FoxParams.MaxEnergy
+	}
+	for i, sample := range g.selectionHistory {
+		x := inspectPanelX + 6 + i*chartWidth/inspectHistoryLength
+		value := sample.Value
+		if value > maxValue {
+			value = maxValue
+		}
+		if value < 0 {
+			value = 0
+		}
+		height := value * 38 / maxValue
+		g.fillRect(screen, x, chartY+38-height, 2, 2, color.RGBA{0, 220, 220, 255})
+	}
+}
+
+func (g *Game) inspectText() string {
+	if g.selected.kind == GrassType {
+		pos := g.selected.cell
+		text := fmt.Sprintf("GRASS at (%d,%d)\n", pos.X, pos.Y)
+		if grass, exists := g.world.Grass[pos]; exists {
+			text += fmt.Sprintf("Amount: %d/%d\n", grass.Amount, maxGrassAmount)
+			if grass.Amount >= g.world.Params.MinGrassToEat {
+				text += "Edible: yes\n"
+			} else {
+				text += "Edible: not yet\n"
+			}
+		} else {
+			text += "Eaten\n"
+		}
+		if g.world.regionsEnabled {
+			text += fmt.Sprintf("Region: %s\n", g.world.regionAt(pos).Name)
+		}
+		return text + g.historyText("Amount")
+	}
+	
+	animal, rabbit, fox := g.selectedAnimal()
+	name := "RABBIT"
+	if g.selected.kind == FoxType {
+		name = "FOX"
+	}
+	if animal == nil {
+		text := fmt.Sprintf("%s #%d\nGONE, last seen at tick %d\n", name, g.selected.id, g.selected.seen)
+		return text + g.historyText("Energy")
+	}
+	
+	params := &g.world.RabbitParams
+	if fox != nil {
+		params = &g.world.FoxParams
+	}
+	
+	text := fmt.Sprintf("%s #%d\n", name, animal.ID)
+	text += fmt.Sprintf("Position: (%d,%d)\n", animal.Position.X, animal.Position.Y)
+	text += fmt.Sprintf("Energy: %d/%d\n", animal.Energy, params.MaxEnergy)
+	text += fmt.Sprintf("Age: %d ticks\n", animal.Age)
+	text += fmt.Sprintf("Cooldown: %d\n", animal.ReproduceCD)
+	if animal.Digesting > 0 {
+		text += fmt.Sprintf("Digesting: %d ticks\n", animal.Digesting)
+	}
+	if animal.Pregnant > 0 {
+		text += fmt.Sprintf("Pregnant: %d ticks (%d young)\n", animal.Pregnant, animal.Litter)
+	}
+	
+	if rabbit != nil {
+		text += fmt.Sprintf("Newborn: %d\n", rabbit.NewBorn)
+	}
+	
+	if fox != nil {
+		if target := g.world.findNearestRabbit(fox.Animal.Position); target != nil {
+			text += fmt.Sprintf("Target: rabbit at (%d,%d)\n", target.X, target.Y)
+		} else {
+			text += "Target: none in sight\n"
+		}
+		if fox.PackID > 0 {
+			text += fmt.Sprintf("Pack: #%d (%d foxes)\n", fox.PackID, len(g.world.Packs[fox.PackID-1]))
+		}
+	}
+	
+	if g.world.regionsEnabled {
+		text += fmt.Sprintf("Region: %s\n", g.world.regionAt(animal.Position).Name)
+	}
+	return text + g.historyText("Energy")
+}
+
+// historyText summarises the recorded history: how the value changed and how
+// far an animal travelled.
+func (g *Game) historyText(label string) string {
+	if len(g.selectionHistory) < 2 {
+		return ""
+	}
+	
+	first := g.selectionHistory[0]
+	last := g.selectionHistory[len(g.selectionHistory)-1]
+	text := fmt.Sprintf("History (ticks %d-%d):\n", first.Tick, last.Tick)
+	text += fmt.Sprintf(" %s %d -> %d\n", label, first.Value, last.Value)
+	
+	if g.selected.kind != GrassType {
+		moved := 0
+		for i := 1; i < len(g.selectionHistory); i++ {
+			moved += chebyshev(g.selectionHistory[i-1].Position, g.selectionHistory[i].Position)
+		}
+		text += fmt.Sprintf(" Moved %d cells\n", moved)
+	}
+	return text
+}
@@ -1,13 +1,17 @@
+// Since Go 1.24 rand.Seed does nothing unless this setting is turned off;
+// -seed relies on it to make runs reproducible.
+//
+//go:debug randseednop=0
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
-	"image/jpeg"
 	"log"
 	"math/rand"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,53 +22,152 @@ import (
 
 type Game struct {
 	world           *World
-	slowMode        bool
 	tickCounter     int
 	paused          bool
-	populationHistory []PopulationData
+	
+	speedLevel      int
+	maxSpeed        bool
+	ticksLastFrame  int
+	targetTick      int
+	enteringTick    bool
+	tickInput       string
+	
+	timeline        *snapshotRing
+	timelineCursor  int // Snapshot being shown after a rewind, -1 when live
+	rewoundFrom     int // Live tick before the rewind
+	scrubbing       bool
+	headless        bool
+	
+	selected         selection
+	selectionHistory []selectionSample
+	history         *populationHistory
 	recordCounter   int
+	sampleInterval  int
+	exportFormats   []*exporter
+	animation       animationOptions
+	imageFormats    []*imageFormat
+	worldFrames     *worldRecorder // World captured at samples for the history animation
 	
 	drawMode        string
-	mousePressed    bool
+	
+	brushRadius   int
+	brushDensity  float64
+	terrainRegion int
+	stroke        *editStroke
+	strokeCells   map[Position]bool
+	undoStack     []*editStroke
+	redoStack     []*editStroke
+	
+	showScent       bool
+	chartScale      chartScale
+	chartView       chartView
+	chartGroup      int
+	
+	showSettings bool
+	paramDrag    *tunable // Parameter whose slider is being dragged
+	paramDragRow int
+	paramDragOld float64
+	
+	regionLayer        *ebiten.Image
+	regionLayerVersion int
+	worldLayer         *ebiten.Image
+	
+	width   int
+	height  int
+	seed    int64
+	workers int
 }
 
 func (g *Game) Update() error {
 	if g.world == nil {
-		g.world = NewWorld()
-		rand.Seed(time.Now().UnixNano())
-		
-		g.world.addTestEntities()
-		
-		g.populationHistory = make([]PopulationData, 0, maxHistoryPoints)
-		g.recordPopulationData()
+		g.newWorld()
 		
 		g.drawMode = "none"
 		
 		log.Println("World initialized with test entities")
-		log.Println("Use keys: 1=Draw Rabbits, 2=Draw Foxes, 0=Normal mode")
+		log.Println("Use keys: 1=Draw Rabbits, 2=Draw Foxes, 3=Grass, 4=Terrain, 5=Eraser, 0=Normal mode")
 	}
 	
 	g.handleInput()
 	
 	if !g.paused {
-		g.tickCounter++
-		if g.tickCounter >= 10 {
-			g.tickCounter = 0
-			g.world.Update()
-			g.world.Tick++
-			
-			g.recordCounter++
-			if g.recordCounter >= 30 {
-				g.recordCounter = 0
-				g.recordPopulationData()
-			}
-		}
+		g.advance()
 	}
 	
 	return nil
 }
 
+// newWorld creates a fresh world from the game's seed, so that a reset with
+// the same seed replays the same run.
+func (g *Game) newWorld() {
+	rand.Seed(g.seed)
+	
+	g.world = NewWorld(g.width, g.height)
+	g.world.Seed = g.seed
+	g.world.workers = g.workers
+	g.world.addTestEntities()
+	
+	if g.history != nil {
+		g.history.close()
+	}
+	g.history = newPopulationHistory(g.sampleInterval)
+	g.worldFrames = newWorldRecorder(g.animation)
+	g.recordCounter = 0
+	g.targetTick = 0
+	g.recordPopulationData()
+	
+	g.selected = selection{}
+	g.selectionHistory = nil
+	g.undoStack = nil
+	g.redoStack = nil
+	
+	g.timeline = newSnapshotRing(snapshotCapacity)
+	g.timelineCursor = -1
+	g.recordSnapshot()
+}
+
+// step advances the simulation by one tick, records population data every
+// sampleInterval ticks and takes the timeline snapshots.
+func (g *Game) step() {
+	g.leaveTimeline()
+	
+	g.world.Update()
+	g.world.Tick++
+	g.recordSnapshot()
+	g.sampleSelection()
+	
+	g.recordCounter++
+	if g.recordCounter >= g.sampleInterval {
+		g.recordCounter = 0
+		g.recordPopulationData()
+	}
+}
+
+// runHeadless runs the simulation without a window for the given number of
+// ticks and saves the data, screenshot, phase plot and animation at the end.
+func (g *Game) runHeadless(ticks int) {
+	g.headless = true
+	g.newWorld()
+	
+	start := time.Now()
+	for i := 0; i < ticks; i++ {
+		g.step()
+		if (i+1)%1000 == 0 {
+			log.Printf("Tick %d: %d rabbits, %d foxes, %d grass (%.1fs)", 
+				g.world.Tick, len(g.world.Rabbits), len(g.world.Foxes), len(g.world.Grass), time.Since(start).Seconds())
+		}
+	}
+	
+	log.Printf("Ran %d ticks with %d worker(s) in %.1fs", ticks, g.workers, time.Since(start).Seconds())
+	g.saveSimulationData()
+	g.history.close()
+}
+
 func (g *Game) handleInput() {
+	if g.handleTickInput() {
+		return
+	}
+	
 	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
 		g.paused = !g.paused
 		if g.paused {
@@ -91,10 +194,95 @@ func (g *Game) handleInput() {
 		g.toggleFoxVision()
 	}
 	
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.toggleCarryingCapacity()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		g.toggleScentTracking()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.togglePackHunting()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		g.toggleHerding()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.toggleRegions()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+		g.toggleMigration()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		g.toggleMetabolism()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.toggleGestation()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+		g.toggleTerrainCover()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyU) {
+		g.toggleUpdateMode()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.showScent = !g.showScent
+		if g.showScent {
+			log.Println("Scent map: ON")
+		} else {
+			log.Println("Scent map: OFF")
+		}
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		g.cycleChartScale()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+		g.cycleChartGroup()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		g.cycleChartView()
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.toggleSettings()
+	}
+	
 	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
 		g.saveSimulationData()
 	}
 	
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyKPSubtract) {
+		g.changeSpeed(-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyKPAdd) {
+		g.changeSpeed(1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		g.toggleMaxSpeed()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyPeriod) {
+		g.stepOnce()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.enteringTick = true
+		g.tickInput = ""
+	}
+	
+	g.handleEditorInput()
+	g.handleTimelineInput()
+	g.handleInspectInput()
 	g.handleMouseInput()
 	
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
@@ -104,78 +292,24 @@ func (g *Game) handleInput() {
 }
 
 func (g *Game) handleMouseInput() {
-	if g.world == nil || g.drawMode == "none" {
-		return
-	}
-	
-	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		if !g.mousePressed {
-			g.mousePressed = true
-			g.handleMouseDraw()
-		}
-	} else {
-		g.mousePressed = false
-	}
-}
-
-func (g *Game) handleMouseDraw() {
-	x, y := ebiten.CursorPosition()
-	
-	if y >= gameAreaHeight || x < 0 || x >= screenWidth {
+	if g.world == nil {
 		return
 	}
 	
-	gridX := x / cellSize
-	gridY := y / cellSize
-	
-	if gridX < 0 || gridX >= gridWidth || gridY < 0 || gridY >= gridHeight {
+	if g.showSettings && g.handleSettingsMouse() {
+		g.endStroke()
 		return
 	}
 	
-	pos := Position{gridX, gridY}
-	
-	if g.world.Grid[gridX][gridY] == RabbitType || g.world.Grid[gridX][gridY] == FoxType {
+	if g.drawMode == "none" {
+		g.endStroke()
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			g.handleInspectClick()
+		}
 		return
 	}
 	
-	switch g.drawMode {
-	case "rabbit":
-		if len(g.world.Rabbits) >= maxRabbits {
-			return
-		}
-		
-		rabbit := &Rabbit{
-			Animal: Animal{
-				Position:    pos,
-				Energy:      80,
-				ReproduceCD: 0,
-				Age:         0,
-			},
-			NewBorn: 60,
-		}
-		
-		g.world.Rabbits = append(g.world.Rabbits, rabbit)
-		g.world.Grid[gridX][gridY] = RabbitType
-		log.Printf("Placed rabbit at (%d,%d)", gridX, gridY)
-		
-	case "fox":
-		if len(g.world.Foxes) >= maxFoxes {
-			return
-		}
-		
-		fox := &Fox{
-			Animal: Animal{
-				Position:    pos,
-				Energy:      80,
-				ReproduceCD: 0,
-				Age:         0,
-			},
-		}
-		
-		g.world.Foxes = append(g.world.Foxes, fox)
-		g.world.Grid[gridX][gridY] = FoxType
-		log.Printf("Placed fox at (%d,%d)", gridX, gridY)
-	}
+	g.handleMouseDraw()
 }
 
 func (g *Game) handleButtonClick(x, y int) {
@@ -193,10 +327,8 @@ func (g *Game) handleButtonClick(x, y int) {
 	
 	// Reset button (700, 10, 80, 30)
 	if x >= 700 && x <= 780 && y >= 10 && y <= 40 {
-		g.world = NewWorld()
-		g.world.addTestEntities()
-		g.populationHistory = make([]PopulationData, 0, maxHistoryPoints)
-		g.recordPopulationData()
+		g.newWorld()
+		g.regionLayerVersion = -1
 		g.paused = false
 		g.drawMode = "none"
 		log.Println("Simulation reset")
@@ -211,282 +343,197 @@ func (g *Game) handleButtonClick(x, y int) {
 func (g *Game) toggleFoxVision() {
 	if g.world != nil {
 		g.world.smartHunting = !g.world.smartHunting
+		g.world.recordToggle("SmartHunting", g.world.smartHunting)
 		if g.world.smartHunting {
-			log.Printf("Fox vision: ENHANCED (range %d cells)", foxVisionRange)
+			log.Printf("Fox vision: ENHANCED (range %d cells)", g.world.Params.FoxVisionRange)
 		} else {
 			log.Println("Fox vision: BASIC (1 cell)")
 		}
 	}
 }
 
-func (g *Game) saveSimulationData() {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	
-	if len(g.populationHistory) > 0 {
-		exportPopulationData(g.populationHistory)
+func (g *Game) toggleCarryingCapacity() {
+	if g.world != nil {
+		g.world.carryingCapacity = !g.world.carryingCapacity
+		g.world.recordToggle("CarryingCapacity", g.world.carryingCapacity)
+		if g.world.carryingCapacity {
+			log.Printf("Population model: CARRYING CAPACITY (safety caps %d/%d)", maxRabbitsSafety, maxFoxesSafety)
+		} else {
+			log.Printf("Population model: HARD CAPS (%d/%d)", g.world.Params.MaxRabbits, g.world.Params.MaxFoxes)
+		}
 	}
-	
-	g.saveScreenshot(timestamp)
-	g.saveHistorySequence(timestamp)
-	
-	log.Printf("Saved simulation data with timestamp: %s", timestamp)
 }
 
-func (g *Game) saveHistorySequence(timestamp string) {
-	if len(g.populationHistory) < 2 {
-		log.Println("Not enough history data for sequence")
-		return
-	}
-	
-	log.Printf("Creating history sequence with %d frames...", len(g.populationHistory))
-	
-	sequenceDir := fmt.Sprintf("ecosystem_sequence_%s", timestamp)
-	err := os.Mkdir(sequenceDir, 0755)
-	if err != nil {
-		log.Printf("Error creating sequence directory: %v", err)
-		return
-	}
-	
-	originalHistory := make([]PopulationData, len(g.populationHistory))
-	copy(originalHistory, g.populationHistory)
-	
-	for i := 0; i < len(originalHistory); i++ {
-		currentHistory := originalHistory[:i+1]
-		
-		filename := fmt.Sprintf("%s/frame_%03d.jpg", sequenceDir, i)
-		g.saveHistoryFrame(filename, currentHistory, originalHistory[i])
-		
-		if i%10 == 0 || i == len(originalHistory)-1 {
-			log.Printf("Generated frame %d/%d", i+1, len(originalHistory))
+func (g *Game) toggleScentTracking() {
+	if g.world != nil {
+		g.world.scentTracking = !g.world.scentTracking
+		g.world.recordToggle("ScentTracking", g.world.scentTracking)
+		if g.world.scentTracking {
+			log.Println("Fox scent tracking: ON")
+		} else {
+			log.Println("Fox scent tracking: OFF")
 		}
 	}
-	
-	g.createSequenceSummary(sequenceDir, timestamp, len(originalHistory))
-	
-	log.Printf("History sequence saved to: %s", sequenceDir)
 }
 
-func (g *Game) saveHistoryFrame(filename string, historyUpToPoint []PopulationData, currentData PopulationData) {
-	bounds := image.Rect(0, 0, screenWidth, screenHeight)
-	img := image.NewRGBA(bounds)
-	
-	screen := ebiten.NewImage(screenWidth, screenHeight)
-	
-	g.renderHistoryFrame(screen, historyUpToPoint, currentData)
-	
-	for y := 0; y < screenHeight; y++ {
-		for x := 0; x < screenWidth; x++ {
-			r, g, b, a := screen.At(x, y).RGBA()
-			img.Set(x, y, color.RGBA{
-				uint8(r >> 8),
-				uint8(g >> 8),
-				uint8(b >> 8),
-				uint8(a >> 8),
-			})
+func (g *Game) togglePackHunting() {
+	if g.world != nil {
+		g.world.packHunting = !g.world.packHunting
+		g.world.recordToggle("PackHunting", g.world.packHunting)
+		if g.world.packHunting {
+			log.Printf("Pack hunting: ON (radius %d cells)", foxPackRadius)
+		} else {
+			log.Println("Pack hunting: OFF")
 		}
 	}
-	
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("Error creating frame file: %v", err)
-		return
-	}
-	defer file.Close()
-	
-	err = jpeg.Encode(file, img, &jpeg.Options{Quality: 80})
-	if err != nil {
-		log.Printf("Error saving frame: %v", err)
-	}
 }
 
-func (g *Game) renderHistoryFrame(screen *ebiten.Image, historyUpToPoint []PopulationData, currentData PopulationData) {
-	screen.Fill(color.RGBA{0, 0, 0, 255})
-	
-	g.fillRect(screen, graphOffsetX, graphOffsetY, graphWidth, graphHeight, color.RGBA{20, 20, 20, 255})
-	
-	g.fillRect(screen, graphOffsetX, graphOffsetY, graphWidth, 2, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX, graphOffsetY+graphHeight-2, graphWidth, 2, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX+graphWidth-2, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
-	
-	if len(historyUpToPoint) < 1 {
-		return
-	}
-	
-	maxValue := 20
-	for _, data := range historyUpToPoint {
-		if data.Rabbits > maxValue { maxValue = data.Rabbits }
-		if data.Foxes > maxValue { maxValue = data.Foxes }
-		if data.Grass/10 > maxValue { maxValue = data.Grass/10 }
+func (g *Game) toggleHerding() {
+	if g.world != nil {
+		g.world.herding = !g.world.herding
+		g.world.recordToggle("Herding", g.world.herding)
+		if g.world.herding {
+			log.Printf("Rabbit herding: ON (radius %d cells)", herdRadius)
+		} else {
+			log.Println("Rabbit herding: OFF")
+		}
 	}
-	
-	g.drawHistoryPoints(screen, historyUpToPoint, "rabbits", maxValue, color.RGBA{255, 255, 255, 255})
-	g.drawHistoryPoints(screen, historyUpToPoint, "foxes", maxValue, color.RGBA{255, 0, 0, 255})
-	g.drawHistoryPoints(screen, historyUpToPoint, "grass", maxValue, color.RGBA{0, 255, 0, 255})
-	
-	title := fmt.Sprintf("Ecosystem Evolution - Tick: %d (Frame %d)", currentData.Tick, len(historyUpToPoint))
-	ebitenutil.DebugPrint(screen, title)
-	
-	stats := fmt.Sprintf("Current: Rabbits=%d  Foxes=%d  Grass=%d", 
-		currentData.Rabbits, currentData.Foxes, currentData.Grass)
-	ebitenutil.DebugPrintAt(screen, stats, 10, 30)
-	
-	ebitenutil.DebugPrintAt(screen, "■=Rabbits ♦=Foxes +=Grass", 30, screenHeight-20)
-	
-	progressWidth := 200
-	progressX := screenWidth - progressWidth - 20
-	progressY := 10
-	progress := float64(len(historyUpToPoint)) / float64(maxHistoryPoints)
-	
-	g.fillRect(screen, progressX, progressY, progressWidth, 10, color.RGBA{50, 50, 50, 255})
-	g.fillRect(screen, progressX, progressY, int(float64(progressWidth)*progress), 10, color.RGBA{0, 150, 0, 255})
 }
 
-func (g *Game) drawHistoryPoints(screen *ebiten.Image, history []PopulationData, populationType string, maxValue int, pointColor color.RGBA) {
-	if len(history) < 1 || maxValue <= 0 {
-		return
-	}
-	
-	var yOffset int
-	switch populationType {
-	case "rabbits": yOffset = 0
-	case "foxes": yOffset = -3  
-	case "grass": yOffset = 3
-	}
-	
-	for i, data := range history {
-		var value int
-		switch populationType {
-		case "rabbits": value = data.Rabbits
-		case "foxes": value = data.Foxes
-		case "grass": value = data.Grass / 10
-		default: continue
-		}
-		
-		if value == 0 { continue }
-		
-		x := graphOffsetX + 5 + ((i * (graphWidth - 10)) / maxHistoryPoints)
-		y := graphOffsetY + graphHeight - 5 - ((value * (graphHeight - 10)) / maxValue) + yOffset
-		
-		if y < graphOffsetY + 5 { y = graphOffsetY + 5 }
-		if y > graphOffsetY + graphHeight - 5 { y = graphOffsetY + graphHeight - 5 }
-		
-		switch populationType {
-		case "rabbits":
-			g.fillRect(screen, x-1, y-1, 3, 3, pointColor)
-		case "foxes":
-			g.fillRect(screen, x, y-1, 1, 1, pointColor)
-			g.fillRect(screen, x-1, y, 1, 1, pointColor)
-			g.fillRect(screen, x+1, y, 1, 1, pointColor)
-			g.fillRect(screen, x, y+1, 1, 1, pointColor)
-		case "grass":
-			g.fillRect(screen, x-1, y, 3, 1, pointColor)
-			g.fillRect(screen, x, y-1, 1, 3, pointColor)
+func (g *Game) toggleRegions() {
+	if g.world != nil {
+		g.world.regionsEnabled = !g.world.regionsEnabled
+		g.world.recordToggle("Regions", g.world.regionsEnabled)
+		if g.world.regionsEnabled {
+			g.world.regionCounts = g.world.countByRegion()
+			log.Println("Regions: ON")
+		} else {
+			log.Println("Regions: OFF (homogeneous grid)")
 		}
 	}
 }
 
-func (g *Game) createSequenceSummary(sequenceDir, timestamp string, frameCount int) {
-	summaryFile := fmt.Sprintf("%s/README.txt", sequenceDir)
-	file, err := os.Create(summaryFile)
-	if err != nil {
-		log.Printf("Error creating summary file: %v", err)
-		return
+func (g *Game) toggleMigration() {
+	if g.world != nil {
+		g.world.migration = !g.world.migration
+		g.world.recordToggle("Migration", g.world.migration)
+		if g.world.migration {
+			log.Println("Seasonal migration: ON")
+		} else {
+			log.Println("Seasonal migration: OFF")
+		}
 	}
-	defer file.Close()
-	
-	summary := fmt.Sprintf(`Ecosystem Simulation History Sequence
-=====================================
-
-Generated: %s
-Frames: %d
-Duration: %d ticks
-Frame rate: One frame per 5 seconds of simulation
-
-Files:
-- frame_000.jpg to frame_%03d.jpg: Individual frames showing population evolution
-- README.txt: This file
-
-To create a video from these frames, you can use tools like:
-
-FFmpeg (example):
-ffmpeg -framerate 10 -i frame_%%03d.jpg -c:v libx264 -pix_fmt yuv420p ecosystem_evolution.mp4
+}
 
-VirtualDub, Adobe Premiere, or other video editing software can also be used.
+func (g *Game) toggleMetabolism() {
+	if g.world != nil {
+		g.world.continuousMetabolism = !g.world.continuousMetabolism
+		g.world.recordToggle("ContinuousMetabolism", g.world.continuousMetabolism)
+		if g.world.continuousMetabolism {
+			log.Println("Metabolism: CONTINUOUS (cost per action)")
+		} else {
+			log.Println("Metabolism: FLAT (1 energy every 60 ticks)")
+		}
+	}
+}
 
-Each frame shows:
-- Population graph growing over time
-- Current population numbers
-- Progress bar showing simulation progress
-- Legend for graph symbols
+func (g *Game) toggleGestation() {
+	if g.world != nil {
+		g.world.gestation = !g.world.gestation
+		g.world.recordToggle("Gestation", g.world.gestation)
+		if g.world.gestation {
+			log.Println("Reproduction: GESTATION and litters")
+		} else {
+			log.Println("Reproduction: INSTANT single births")
+		}
+	}
+}
 
-This sequence captures the complete evolution of your ecosystem simulation!
-`, time.Now().Format("2006-01-02 15:04:05"), frameCount, 
-   g.populationHistory[len(g.populationHistory)-1].Tick, frameCount-1)
-	
-	file.WriteString(summary)
-	log.Printf("Created sequence summary: %s", summaryFile)
+func (g *Game) toggleTerrainCover() {
+	if g.world != nil {
+		g.world.terrainCover = !g.world.terrainCover
+		g.world.recordToggle("TerrainCover", g.world.terrainCover)
+		if g.world.terrainCover {
+			log.Println("Terrain cover: ON (grass and forest hide rabbits)")
+		} else {
+			log.Println("Terrain cover: OFF")
+		}
+	}
 }
 
-func (g *Game) saveScreenshot(timestamp string) {
-	bounds := image.Rect(0, 0, screenWidth, screenHeight)
-	img := image.NewRGBA(bounds)
-	
-	screen := ebiten.NewImage(screenWidth, screenHeight)
-	
-	g.renderToImage(screen)
-	
-	for y := 0; y < screenHeight; y++ {
-		for x := 0; x < screenWidth; x++ {
-			r, g, b, a := screen.At(x, y).RGBA()
-			img.Set(x, y, color.RGBA{
-				uint8(r >> 8),
-				uint8(g >> 8), 
-				uint8(b >> 8),
-				uint8(a >> 8),
-			})
+func (g *Game) toggleUpdateMode() {
+	if g.world != nil {
+		g.world.synchronous = !g.world.synchronous
+		g.world.recordToggle("Synchronous", g.world.synchronous)
+		if g.world.synchronous {
+			log.Println("Update mode: SYNCHRONOUS (all animals move at once)")
+		} else {
+			log.Println("Update mode: SEQUENTIAL")
 		}
 	}
+}
+
+func (g *Game) saveSimulationData() {
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	
-	filename := fmt.Sprintf("ecosystem_screenshot_%s.jpg", timestamp)
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("Error creating screenshot file: %v", err)
-		return
-	}
-	defer file.Close()
+	exportPopulationData(g.history, g.world, g.exportFormats)
 	
-	err = jpeg.Encode(file, img, &jpeg.Options{Quality: 90})
-	if err != nil {
-		log.Printf("Error saving screenshot: %v", err)
-		return
-	}
+	g.saveScreenshot(timestamp)
+	g.savePhasePlot(timestamp)
+	g.saveHistorySequence(timestamp)
 	
-	log.Printf("Screenshot saved: %s", filename)
+	log.Printf("Saved simulation data with timestamp: %s", timestamp)
 }
 
-func (g *Game) renderToImage(screen *ebiten.Image) {
-	screen.Fill(color.RGBA{0, 0, 0, 255})
-	
-	if g.world != nil {
-		g.drawWorld(screen)
+// saveHistorySequence writes the run as an animation: the world at each
+// captured frame above the population chart up to that tick. Without
+// captured world frames, as in the data viewer, only the chart is animated.
+func (g *Game) saveHistorySequence(timestamp string) {
+	history := g.history.downsampled(historyDisplayPoints)
+	if len(history) < 2 {
+		log.Println("Not enough history data for animation")
+		return
 	}
 	
-	if g.world != nil {
-		g.drawPopulationGraph(screen)
+	type frame struct {
+		history []PopulationData
+		world   *worldFrame
 	}
-	
-	title := fmt.Sprintf("Ecosystem Simulation - Tick: %d", g.world.Tick)
-	if g.paused {
-		title += " (PAUSED)"
+	var frames []frame
+	if g.worldFrames != nil && len(g.worldFrames.frames) > 1 {
+		for i := range g.worldFrames.frames {
+			world := &g.worldFrames.frames[i]
+			n := sort.Search(len(history), func(j int) bool { return history[j].Tick > world.tick })
+			if n > 0 {
+				frames = append(frames, frame{history[:n], world})
+			}
+		}
+	} else {
+		// Long runs are averaged down so the animation still covers the whole run
+		samples := g.history.downsampled(maxHistoryPoints)
+		for i := range samples {
+			frames = append(frames, frame{samples[:i+1], nil})
+		}
 	}
-	ebitenutil.DebugPrint(screen, title)
 	
-	if g.world != nil {
-		info := fmt.Sprintf("Rabbits: %d  Foxes: %d  Grass: %d", 
-			len(g.world.Rabbits), len(g.world.Foxes), len(g.world.Grass))
-		ebitenutil.DebugPrintAt(screen, info, 10, screenHeight-20)
+	log.Printf("Creating history animation with %d frames...", len(frames))
+	
+	c := newCanvas(screenWidth, screenHeight)
+	filename := fmt.Sprintf("ecosystem_history_%s.%s", timestamp, g.animation.format.ext)
+	err := writeAnimation(filename, len(frames), g.animation, func(i int) *image.RGBA {
+		g.renderHistoryFrame(c, frames[i].history, frames[i].world)
+		
+		if i%10 == 0 || i == len(frames)-1 {
+			log.Printf("Generated frame %d/%d", i+1, len(frames))
+		}
+		return c.RGBA
+	})
+	if err != nil {
+		log.Printf("Error saving history animation: %v", err)
+		return
 	}
+	
+	log.Printf("History animation saved to: %s (%dx%d, %d fps)", filename, g.animation.width, g.animation.height(), g.animation.fps)
 }
 
 func (g *Game) recordPopulationData() {
@@ -496,15 +543,29 @@ func (g *Game) recordPopulationData() {
 	
 	data := PopulationData{
 		Tick:    g.world.Tick,
+		Time:    time.Now(),
 		Rabbits: len(g.world.Rabbits),
 		Foxes:   len(g.world.Foxes),
 		Grass:   len(g.world.Grass),
+		Regions: g.world.countByRegion(),
+		HuntAttempts:  g.world.HuntAttempts,
+		HuntSuccesses: g.world.HuntSuccesses,
+		
+		GrassBiomass: g.world.grassBiomass(),
+		RabbitStats:  g.world.rabbitStats(),
+		FoxStats:     g.world.foxStats(),
+		Events:       g.world.Events,
 	}
 	
-	g.populationHistory = append(g.populationHistory, data)
+	if g.history.len() > 0 {
+		prev := g.history.last()
+		data.RecentEvents = data.Events.since(prev.Events)
+		data.Hunts = data.HuntAttempts - prev.HuntAttempts
+	}
 	
-	if len(g.populationHistory) > maxHistoryPoints {
-		g.populationHistory = g.populationHistory[1:]
+	g.history.add(data)
+	if g.worldFrames != nil {
+		g.worldFrames.sample(g.world)
 	}
 }
 
@@ -518,7 +579,11 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	g.drawControlButtons(screen)
 	
 	if g.world != nil {
+		if g.drawMode == "none" && !g.showSettings {
+			g.drawInspectPanel(screen)
+		}
 		g.drawPopulationGraph(screen)
+		g.drawTimeline(screen)
 	}
 	
 	debugText := "Ecosystem Simulation"
@@ -528,19 +593,27 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	debugText += "\n"
 	
 	if g.world != nil {
-		debugText += fmt.Sprintf("Tick: %d\n", g.world.Tick)
+		debugText += fmt.Sprintf("Tick: %d", g.world.Tick)
+		if g.world.workers > 1 {
+			debugText += fmt.Sprintf(" (parallel, %d workers)", g.world.workers)
+		} else if g.world.synchronous {
+			debugText += " (synchronous)"
+		}
+		debugText += "\n"
+		debugText += g.speedStatus() + "\n"
+		debugText += g.timelineStatus() + "\n"
 		debugText += fmt.Sprintf("Grass: %d\n", len(g.world.Grass))
 		
 		rabbitCount := len(g.world.Rabbits)
 		debugText += fmt.Sprintf("Rabbits: %d", rabbitCount)
-		if rabbitCount >= maxRabbits {
+		if rabbitCount >= g.world.rabbitLimit() {
 			debugText += " (MAX!)"
 		}
 		debugText += "\n"
 		
 		foxCount := len(g.world.Foxes)
 		debugText += fmt.Sprintf("Foxes: %d", foxCount)
-		if foxCount >= maxFoxes {
+		if foxCount >= g.world.foxLimit() {
 			debugText += " (MAX!)"
 		}
 		if foxCount == 0 {
@@ -567,15 +640,83 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			debugText += fmt.Sprintf("Avg Fox Energy: %d\n", avgFoxEnergy)
 		}
 		
-		debugText += fmt.Sprintf("Draw Mode: %s\n", strings.ToUpper(g.drawMode))
+		debugText += fmt.Sprintf("Draw Mode: %s", strings.ToUpper(g.drawMode))
+		if g.drawMode != "none" {
+			debugText += fmt.Sprintf(" (brush %d, %d%%)", g.brushRadius, int(g.brushDensity*100+0.5))
+		}
+		debugText += "\n"
+		
+		huntRate := 0.0
+		if g.world.HuntAttempts > 0 {
+			huntRate = 100 * float64(g.world.HuntSuccesses) / float64(g.world.HuntAttempts)
+		}
+		debugText += fmt.Sprintf("Hunts: %d/%d (%.0f%%)", g.world.HuntSuccesses, g.world.HuntAttempts, huntRate)
+		if g.world.terrainCover {
+			debugText += " Cover: ON"
+		}
+		debugText += "\n"
 		
 		if g.world.smartHunting {
-			debugText += fmt.Sprintf("Fox Vision: ENHANCED (%d cells)\n", foxVisionRange)
+			debugText += fmt.Sprintf("Fox Vision: ENHANCED (%d cells)\n", g.world.Params.FoxVisionRange)
 		} else {
 			debugText += "Fox Vision: BASIC (1 cell)\n"
 		}
 		
-		debugText += "Controls: SPACE=Pause 1=Rabbit 2=Fox 0=None V=Vision S=Save"
+		if g.world.carryingCapacity {
+			debugText += "Population: CARRYING CAPACITY\n"
+		} else {
+			debugText += "Population: HARD CAPS\n"
+		}
+		
+		if g.world.scentTracking {
+			debugText += "Scent Tracking: ON\n"
+		} else {
+			debugText += "Scent Tracking: OFF\n"
+		}
+		
+		if g.world.packHunting {
+			packFoxes := 0
+			for _, pack := range g.world.Packs {
+				packFoxes += len(pack)
+			}
+			debugText += fmt.Sprintf("Packs: %d (%d foxes)\n", len(g.world.Packs), packFoxes)
+		} else {
+			debugText += "Packs: OFF\n"
+		}
+		
+		if g.world.herding {
+			debugText += fmt.Sprintf("Herding: ON (avg %.1f mates)\n", g.world.averageHerdSize())
+		} else {
+			debugText += "Herding: OFF\n"
+		}
+		
+		if g.world.continuousMetabolism {
+			debugText += "Metabolism: CONTINUOUS\n"
+		} else {
+			debugText += "Metabolism: FLAT\n"
+		}
+		
+		if g.world.gestation {
+			pregnantRabbits, pregnantFoxes := g.world.countPregnant()
+			debugText += fmt.Sprintf("Gestation: ON (pregnant R=%d F=%d)\n", pregnantRabbits, pregnantFoxes)
+		} else {
+			debugText += "Gestation: OFF\n"
+		}
+		
+		if g.world.regionsEnabled {
+			debugText += fmt.Sprintf("Regions: ON (%s)", seasonNames[g.world.season()])
+			if g.world.migration {
+				debugText += " Migration: ON"
+			}
+			debugText += "\n"
+			for r, count := range g.world.regionCounts {
+				debugText += fmt.Sprintf("  %s: R=%d F=%d G=%d\n", g.world.Regions[r].Name, count.Rabbits, count.Foxes, count.Grass)
+			}
+		} else {
+			debugText += "Regions: OFF\n"
+		}
+		
+		debugText += "Controls: SPACE=Pause 1=Rabbit 2=Fox 3=Grass 4=Terrain 5=Eraser 0=None []=Brush ;'=Density R=Region Ctrl+Z/Y=Undo/Redo V=Vision C=Capacity N=Scent M=Map P=Packs H=Herd T=Regions I=Migrate E=Metabolism L=Litters O=Cover U=Update K=Params A=Chart scale Q=Chart view D=Chart series S=Save -/+=Speed F=Max .=Step G=Go to tick Left/Right=Rewind Click=Inspect ESC=Deselect"
 	}
 	
 	ebitenutil.DebugPrint(screen, debugText)
@@ -586,38 +727,19 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	ebitenutil.DebugPrintAt(screen, "SAVE DATA + SCREENSHOT", 535, 60)
 	
 	if g.drawMode != "none" {
-		g.drawCursor(screen)
+		g.drawBrush(screen)
 	}
-	
-	ebitenutil.DebugPrintAt(screen, "■=Rabbits ♦=Foxes +=Grass", 30, 580)
-}
-
-func (g *Game) drawCursor(screen *ebiten.Image) {
-	x, y := ebiten.CursorPosition()
-	
-	if y >= gameAreaHeight || x < 0 || x >= screenWidth {
-		return
+	if g.showSettings && g.world != nil {
+		g.drawSettingsPanel(screen)
 	}
 	
-	gridX := x / cellSize
-	gridY := y / cellSize
-	
-	if gridX < 0 || gridX >= gridWidth || gridY < 0 || gridY >= gridHeight {
-		return
+	if g.world != nil && g.history.len() > 0 {
+		g.drawChartLegend(screen)
+		g.drawChartHover(screen)
 	}
-	
-	drawX := gridX * cellSize
-	drawY := gridY * cellSize
-	
-	var cursorColor color.RGBA
-	switch g.drawMode {
-	case "rabbit":
-		cursorColor = color.RGBA{255, 255, 255, 128}
-	case "fox":
-		cursorColor = color.RGBA{255, 0, 0, 128}
+	if g.drawMode != "none" {
+		g.drawToolbar(screen)
 	}
-	
-	g.fillRect(screen, drawX+1, drawY+1, cellSize-2, cellSize-2, cursorColor)
 }
 
 func (g *Game) drawControlButtons(screen *ebiten.Image) {
@@ -643,92 +765,95 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 }
 
 func main() {
+	width := flag.Int("width", gridWidth, "grid width in cells")
+	height := flag.Int("height", gridHeight, "grid height in cells")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for the simulation")
+	workers := flag.Int("workers", parallelWorkers, "number of worker goroutines; more than 1 enables the parallel update")
+	headless := flag.Bool("headless", false, "run without a window and export the data at the end")
+	ticks := flag.Int("ticks", 10000, "number of ticks to run in headless mode")
+	sample := flag.Int("sample", defaultSampleInterval, "ticks between population samples")
+	format := flag.String("format", "csv", "comma-separated export formats: "+formatNames())
+	view := flag.String("view", "", "comma-separated CSV or columnar (.col) exports to view instead of running the simulation")
+	frames := flag.Bool("frames", false, "with -view, write the history animation of each file and exit")
+	anim := flag.String("anim", "gif", "history animation format: "+animationFormatNames())
+	animFPS := flag.Int("anim-fps", 10, fmt.Sprintf("frames per second of the history animation (1-%d)", maxAnimationFPS))
+	animWidth := flag.Int("anim-width", screenWidth/2, "width in pixels of the history animation")
+	imageList := flag.String("image", "jpeg", "comma-separated formats of screenshots and plots: "+imageFormatNames())
+	flag.Parse()
+	
+	animFormat, err := parseAnimationFormat(*anim)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *animFPS < 1 {
+		*animFPS = 1
+	}
+	if *animFPS > maxAnimationFPS {
+		log.Printf("Animation limited to %d fps", maxAnimationFPS)
+		*animFPS = maxAnimationFPS
+	}
+	if *animWidth < 80 {
+		*animWidth = 80
+	}
+	animation := animationOptions{format: animFormat, fps: *animFPS, width: *animWidth}
+	images, err := parseImageFormats(*imageList)
+	if err != nil {
+		log.Fatal(err)
+	}
+	
+	if *view != "" {
+		if err := runReplayViewer(strings.Split(*view, ","), *frames, animation); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	
+	if *workers < 1 {
+		*workers = 1
+	}
+	if *sample < 1 {
+		*sample = 1
+	}
+	if *width < minGridSize || *height < minGridSize {
+		log.Fatalf("Grid must be at least %dx%d cells, got %dx%d", minGridSize, minGridSize, *width, *height)
+	}
+	formats, err := parseFormats(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	
+	game := &Game{width: *width, height: *height, seed: *seed, workers: *workers, speedLevel: defaultSpeedLevel, brushDensity: 1, sampleInterval: *sample, exportFormats: formats, animation: animation, imageFormats: images}
+	log.Printf("Grid: %dx%d, seed: %d, workers: %d", *width, *height, *seed, *workers)
+	
+	if *headless {
+		game.runHeadless(*ticks)
+		return
+	}
+	
 	log.Println("Starting Ecosystem Simulation...")
 	
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("Ecosystem Simulation - Grass, Rabbits, and Foxes")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	
-	game := &Game{}
-	
 	defer func() {
-		if game.world != nil && len(game.populationHistory) > 5 {
+		if game.world != nil && game.history.len() > 5 {
 			timestamp := time.Now().Format("2006-01-02_15-04-05")
 			
 			log.Println("Saving final simulation data...")
-			exportPopulationData(game.populationHistory)
+			exportPopulationData(game.history, game.world, game.exportFormats)
 			
-			log.Println("Creating complete history sequence...")
+			log.Println("Creating complete history animation...")
 			game.saveHistorySequence(timestamp)
 			
 			log.Println("Simulation data export complete!")
 		}
+		if game.history != nil {
+			game.history.close()
+		}
 	}()
 	
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
 	}
 }
-
-func exportPopulationData(history []PopulationData) {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("ecosystem_data_%s.csv", timestamp)
-	
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("Error creating CSV file: %v", err)
-		return
-	}
-	defer file.Close()
-	
-	file.WriteString("# Ecosystem Simulation Data Export\n")
-	file.WriteString(fmt.Sprintf("# Generated: %s\n", time.Now().Format("2006-01-02 15:04:05")))
-	file.WriteString(fmt.Sprintf("# Duration: %d ticks (%d data points)\n", history[len(history)-1].Tick, len(history)))
-	file.WriteString(fmt.Sprintf("# Data recorded every 5 seconds (30 ticks)\n"))
-	file.WriteString("# \n")
-	file.WriteString("# Simulation parameters:\n")
-	file.WriteString(fmt.Sprintf("# - Grid size: %dx%d\n", gridWidth, gridHeight))
-	file.WriteString(fmt.Sprintf("# - Max rabbits: %d\n", maxRabbits))
-	file.WriteString(fmt.Sprintf("# - Max foxes: %d\n", maxFoxes))
-	file.WriteString(fmt.Sprintf("# - Grass growth rate: %d per tick\n", grassGrowthRate))
-	file.WriteString(fmt.Sprintf("# - Grass spawn chance: %.3f per tick\n", grassSpawnChance))
-	file.WriteString("# \n")
-	
-	_, err = file.WriteString("Tick,Rabbits,Foxes,Grass,Timestamp\n")
-	if err != nil {
-		log.Printf("Error writing CSV header: %v", err)
-		return
-	}
-	
-	startTime := time.Now().Add(-time.Duration(len(history)) * 5 * time.Second)
-	for i, data := range history {
-		rowTime := startTime.Add(time.Duration(i) * 5 * time.Second)
-		line := fmt.Sprintf("%d,%d,%d,%d,%s\n", 
-			data.Tick, 
-			data.Rabbits, 
-			data.Foxes, 
-			data.Grass,
-			rowTime.Format("15:04:05"))
-		
-		_, err = file.WriteString(line)
-		if err != nil {
-			log.Printf("Error writing CSV data: %v", err)
-			return
-		}
-	}
-	
-	log.Printf("Population data exported to: %s", filename)
-	log.Printf("Exported %d data points covering %d ticks", len(history), history[len(history)-1].Tick)
-	
-	if len(history) > 1 {
-		maxRabbits := 0
-		maxFoxes := 0
-		maxGrass := 0
-		for _, data := range history {
-			if data.Rabbits > maxRabbits { maxRabbits = data.Rabbits }
-			if data.Foxes > maxFoxes { maxFoxes = data.Foxes }
-			if data.Grass > maxGrass { maxGrass = data.Grass }
-		}
-		log.Printf("Peak populations: Rabbits=%d, Foxes=%d, Grass=%d", maxRabbits, maxFoxes, maxGrass)
-	}
-}
\ No newline at end of file
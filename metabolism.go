@@ -0,0 +1,93 @@
+package main
+
+// Per-species energy parameters. With continuous metabolism every tick costs
+// energy depending on what the animal did: resting, moving, chasing prey,
+// and digesting a meal all have their own price.
+
+type SpeciesParams struct {
+	MaxEnergy     int
+	IdleCost      float64 // Energy per tick when the animal stays put
+	MoveCost      float64 // Energy per tick spent moving
+	ChaseCost     float64 // Energy per tick spent chasing prey (replaces MoveCost)
+	ReproduceCost float64 // Energy paid by each parent when mating
+	DigestCost    float64 // Energy per tick while digesting a meal
+	DigestTicks   int     // How long digesting a meal takes
+	
+	GestationTicks int       // Length of pregnancy
+	LitterSizes    []float64 // Relative chance of a litter of 1, 2, 3, ... young
+}
+
+func defaultRabbitParams() SpeciesParams {
+	return SpeciesParams{
+		MaxEnergy:     100,
+		IdleCost:      rabbitIdleCost,
+		MoveCost:      rabbitMoveCost,
+		ChaseCost:     rabbitMoveCost,
+		ReproduceCost: 20,
+		DigestCost:    rabbitDigestCost,
+		DigestTicks:   digestTicks,
+		
+		GestationTicks: rabbitGestationTicks,
+		LitterSizes:    []float64{0.2, 0.35, 0.3, 0.15},
+	}
+}
+
+func defaultFoxParams() SpeciesParams {
+	return SpeciesParams{
+		MaxEnergy:     150,
+		IdleCost:      foxIdleCost,
+		MoveCost:      foxMoveCost,
+		ChaseCost:     foxChaseCost,
+		ReproduceCost: 30,
+		DigestCost:    foxDigestCost,
+		DigestTicks:   digestTicks,
+		
+		GestationTicks: foxGestationTicks,
+		LitterSizes:    []float64{0.4, 0.4, 0.2},
+	}
+}
+
+type activity int
+
+const (
+	activityIdle activity = iota
+	activityMove
+	activityChase
+)
+
+// metabolize charges an animal for this tick. Without continuous metabolism
+// the old flat loss every 60 ticks applies regardless of activity.
+func (w *World) metabolize(animal *Animal, params *SpeciesParams, action activity, flatLoss int) {
+	if !w.continuousMetabolism {
+		if w.Tick%60 == 0 {
+			animal.Energy -= flatLoss
+		}
+		return
+	}
+	
+	var cost float64
+	switch action {
+	case activityIdle:
+		cost = params.IdleCost
+	case activityMove:
+		cost = params.MoveCost
+	case activityChase:
+		cost = params.ChaseCost
+	}
+	
+	if animal.Digesting > 0 {
+		cost += params.DigestCost
+		animal.Digesting--
+	}
+	
+	spendEnergy(animal, cost)
+}
+
+// feed adds energy from a meal, capped at the species maximum, and starts digestion.
+func feed(animal *Animal, params *SpeciesParams, gain int) {
+	animal.Energy += gain
+	if animal.Energy > params.MaxEnergy {
+		animal.Energy = params.MaxEnergy
+	}
+	animal.Digesting = params.DigestTicks
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// Metadata written with exported data: everything needed to tell where the
+// numbers came from and to run the same simulation again.
+
+// timestampFormat is used for wall-clock times in exported files.
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// programVersion returns the version followed by the VCS revision the binary
+// was built from, when Go recorded one.
+func programVersion() string {
+	v := version
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" {
+		v += " " + revision
+		if modified {
+			v += "-dirty"
+		}
+	}
+	return v
+}
+
+type RunMetadata struct {
+	Program   string
+	Version   string
+	GoVersion string
+	Args      []string
+	Started   time.Time // Wall-clock time the run was started or reset
+	Generated time.Time
+	
+	Seed           int64
+	GridWidth      int
+	GridHeight     int
+	Workers        int
+	TileSize       int
+	SampleInterval int // Ticks between samples
+	Ticks          int // Tick of the last sample
+	Samples        int
+	
+	Features     map[string]bool
+	Params       SimParams
+	ParamLog     []ParamChange
+	ChangeLog    []RunChange // Feature toggles, edits and rewinds
+	RabbitParams SpeciesParams
+	FoxParams    SpeciesParams
+	Regions      []Region
+	Constants    map[string]float64 // Remaining tunables from constants.go
+}
+
+func runMetadata(history *populationHistory, world *World) RunMetadata {
+	meta := RunMetadata{
+		Program:   "ecosystem-sim",
+		Version:   programVersion(),
+		GoVersion: runtime.Version(),
+		Args:      os.Args[1:],
+		Started:   history.started,
+		Generated: time.Now(),
+		
+		Seed:           world.Seed,
+		GridWidth:      world.Width,
+		GridHeight:     world.Height,
+		Workers:        world.workers,
+		TileSize:       parallelTileSize,
+		SampleInterval: history.interval,
+		Samples:        history.len(),
+		
+		Features: map[string]bool{
+			"SmartHunting":         world.smartHunting,
+			"CarryingCapacity":     world.carryingCapacity,
+			"ScentTracking":        world.scentTracking,
+			"PackHunting":          world.packHunting,
+			"Herding":              world.herding,
+			"Regions":              world.regionsEnabled,
+			"Migration":            world.migration,
+			"ContinuousMetabolism": world.continuousMetabolism,
+			"Gestation":            world.gestation,
+			"TerrainCover":         world.terrainCover,
+			"Synchronous":          world.synchronous,
+		},
+		Params:       world.Params,
+		ParamLog:     world.ParamLog,
+		ChangeLog:    world.ChangeLog,
+		RabbitParams: world.RabbitParams,
+		FoxParams:    world.FoxParams,
+		Regions:      world.Regions,
+		Constants: map[string]float64{
+			"maxGrassAmount":        maxGrassAmount,
+			"scentDeposit":          scentDeposit,
+			"scentDecay":            scentDecay,
+			"scentThreshold":        scentThreshold,
+			"maxScent":              maxScent,
+			"foxPackRadius":         foxPackRadius,
+			"packShareFraction":     packShareFraction,
+			"herdRadius":            herdRadius,
+			"cohesionWeight":        cohesionWeight,
+			"separationWeight":      separationWeight,
+			"alignmentWeight":       alignmentWeight,
+			"forageWeight":          forageWeight,
+			"fleeWeight":            fleeWeight,
+			"herdNoise":             herdNoise,
+			"rabbitVisionRange":     rabbitVisionRange,
+			"herdVigilanceStep":     herdVigilanceStep,
+			"maxVigilanceBonus":     maxVigilanceBonus,
+			"regionSeeds":           regionSeeds,
+			"seasonLength":          seasonLength,
+			"migrationBias":         migrationBias,
+			"catchBaseChance":       catchBaseChance,
+			"catchEnergyWeight":     catchEnergyWeight,
+			"catchAgeBonus":         catchAgeBonus,
+			"youngAge":              youngAge,
+			"elderAge":              elderAge,
+			"grassCoverWeight":      grassCoverWeight,
+			"forestCover":           forestCover,
+			"minCatchChance":        minCatchChance,
+			"maxCatchChance":        maxCatchChance,
+			"huntFailCost":          huntFailCost,
+			"birthSearchRadius":     birthSearchRadius,
+			"crowdingRadius":        crowdingRadius,
+			"rabbitLocalCapacity":   rabbitLocalCapacity,
+			"foxLocalCapacity":      foxLocalCapacity,
+			"foodSearchRadius":      foodSearchRadius,
+			"rabbitFoodSaturation":  rabbitFoodSaturation,
+			"foxFoodSaturation":     foxFoodSaturation,
+			"minFoodFactor":         minFoodFactor,
+			"crowdingEnergyPenalty": crowdingEnergyPenalty,
+			"maxRabbitsSafety":      maxRabbitsSafety,
+			"maxFoxesSafety":        maxFoxesSafety,
+		},
+	}
+	if history.len() > 0 {
+		meta.Ticks = history.last().Tick
+	}
+	return meta
+}
+
+// writeMetadataJSON writes the metadata next to an exported data file.
+func writeMetadataJSON(filename string, meta RunMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+)
+
+// Offscreen rendering of the window's contents onto a canvas, for
+// screenshots and the history animation. The world is drawn cell by cell at
+// the size it has on screen, with the colours of drawWorld; the chart uses
+// the same code as the window.
+
+// renderWorld draws the world like drawWorld, without the selection.
+func (g *Game) renderWorld(c canvas) {
+	w := g.world
+	pixels := float64(g.cellSize()) * g.worldScale()
+	cell := func(pos Position, inset float64, col color.Color) {
+		size := float32((1 - 2*inset) * pixels)
+		c.fillRect(float32((float64(pos.X)+inset)*pixels), float32((float64(pos.Y)+inset)*pixels), size, size, col)
+	}
+	
+	if w.regionsEnabled {
+		for x := 0; x < w.Width; x++ {
+			for y := 0; y < w.Height; y++ {
+				cell(Position{x, y}, 0, w.Regions[w.RegionMap[x][y]].Color)
+			}
+		}
+	}
+	
+	for pos, grass := range w.Grass {
+		cell(pos, 0, grassColor(grass.Amount))
+	}
+	
+	if g.showScent {
+		for x := 0; x < w.Width; x++ {
+			for y := 0; y < w.Height; y++ {
+				if strength := w.Scent[x][y]; strength >= scentThreshold {
+					cell(Position{x, y}, 0, scentColor(strength))
+				}
+			}
+		}
+	}
+	
+	for _, rabbit := range w.Rabbits {
+		cell(rabbit.Animal.Position, 0.3, rabbitColor(rabbit))
+	}
+	
+	for _, fox := range w.Foxes {
+		cell(fox.Animal.Position, 0.1, foxColor)
+		if fox.PackID == 0 {
+			continue
+		}
+		
+		packColor := packColors[(fox.PackID-1)%len(packColors)]
+		x, y, size := float32(float64(fox.Animal.Position.X)*pixels), float32(float64(fox.Animal.Position.Y)*pixels), float32(pixels)
+		c.fillRect(x, y, size, 1, packColor)
+		c.fillRect(x, y+size-1, size, 1, packColor)
+		c.fillRect(x, y, 1, size, packColor)
+		c.fillRect(x+size-1, y, 1, size, packColor)
+	}
+}
+
+// renderScreenshot draws the world, the chart, the tick and the population
+// counts.
+func (g *Game) renderScreenshot() canvas {
+	c := newCanvas(screenWidth, screenHeight)
+	c.fill(color.RGBA{0, 0, 0, 255})
+	
+	g.renderWorld(c)
+	g.drawGraph(c)
+	
+	title := fmt.Sprintf("Ecosystem Simulation - Tick: %d", g.world.Tick)
+	if g.paused {
+		title += " (PAUSED)"
+	}
+	c.print(title, 0, 0)
+	
+	info := fmt.Sprintf("Rabbits: %d  Foxes: %d  Grass: %d",
+		len(g.world.Rabbits), len(g.world.Foxes), len(g.world.Grass))
+	c.print(info, 10, screenHeight-20)
+	return c
+}
+
+// renderHistoryFrame draws one frame of the history animation: the captured
+// world, if any, above the chart of the history up to the frame's sample.
+func (g *Game) renderHistoryFrame(c canvas, historyUpToPoint []PopulationData, world *worldFrame) {
+	c.fill(color.RGBA{0, 0, 0, 255})
+	
+	if world != nil {
+		pixels := float64(fitCellSize(world.gridWidth, world.gridHeight)) * fitWorldScale(world.gridWidth, world.gridHeight)
+		c.drawScaled(world.cells, 0, 0, pixels*float64(world.gridWidth)/float64(world.cells.Rect.Dx()))
+	}
+	
+	g.drawGraphFrame(c)
+	
+	if len(historyUpToPoint) < 1 {
+		return
+	}
+	currentData := historyUpToPoint[len(historyUpToPoint)-1]
+	
+	// Every frame uses the time axis of the whole sequence, so the lines grow
+	// from left to right
+	lastTick := currentData.Tick
+	if g.history.len() > 0 {
+		lastTick = g.history.last().Tick
+	}
+	group := chartGroups[g.chartGroup]
+	chart := newPopulationChart(historyUpToPoint, group.series, historyUpToPoint[0].Tick, lastTick, g.chartScale)
+	chart.draw(c, historyUpToPoint)
+	
+	c.print(fmt.Sprintf("Ecosystem Evolution - Tick: %d", currentData.Tick), 0, 0)
+	
+	stats := fmt.Sprintf("Current: Rabbits=%d  Foxes=%d  Grass=%d",
+		currentData.Rabbits, currentData.Foxes, currentData.Grass)
+	c.print(stats, 10, 30)
+	
+	chart.drawLegend(c, group.name, 30, screenHeight-22)
+	
+	progressWidth := float32(200)
+	progressX := float32(screenWidth) - progressWidth - 20
+	progressY := float32(10)
+	progress := 1.0
+	if lastTick > historyUpToPoint[0].Tick {
+		progress = float64(currentData.Tick-historyUpToPoint[0].Tick) / float64(lastTick-historyUpToPoint[0].Tick)
+	}
+	
+	c.fillRect(progressX, progressY, progressWidth, 10, color.RGBA{50, 50, 50, 255})
+	c.fillRect(progressX, progressY, progressWidth*float32(progress), 10, color.RGBA{0, 150, 0, 255})
+}
+
+// saveScreenshot writes the current state as an image in each of the chosen
+// formats.
+func (g *Game) saveScreenshot(timestamp string) {
+	files, err := saveImage("ecosystem_screenshot_"+timestamp, g.renderScreenshot(), g.imageFormats)
+	if err != nil {
+		log.Printf("Error saving screenshot: %v", err)
+		return
+	}
+	log.Printf("Screenshot saved: %s", strings.Join(files, ", "))
+}
@@ -0,0 +1,162 @@
+package main
+
+import "sort"
+
+// Foxes within foxPackRadius of each other (directly or through a chain of
+// packmates) form a pack. A pack agrees on one rabbit, each member heads for a
+// different cell around it so the rabbit gets surrounded, and kills are shared
+// with packmates nearby.
+
+var packFlankOffsets = []Position{
+	{0, -1}, {1, 0}, {0, 1}, {-1, 0},
+	{1, -1}, {1, 1}, {-1, 1}, {-1, -1},
+}
+
+func (w *World) formPacks() {
+	w.Packs = w.Packs[:0]
+	for _, fox := range w.Foxes {
+		fox.PackID = 0
+		fox.PackTarget = nil
+	}
+	
+	if !w.packHunting {
+		return
+	}
+	
+	visited := make(map[*Fox]bool)
+	for _, fox := range w.Foxes {
+		if visited[fox] {
+			continue
+		}
+		
+		pack := []*Fox{fox}
+		visited[fox] = true
+		for i := 0; i < len(pack); i++ {
+			for _, other := range w.Foxes {
+				if !visited[other] && chebyshev(pack[i].Animal.Position, other.Animal.Position) <= foxPackRadius {
+					visited[other] = true
+					pack = append(pack, other)
+				}
+			}
+		}
+		
+		if len(pack) < 2 {
+			continue
+		}
+		
+		w.Packs = append(w.Packs, pack)
+		for _, member := range pack {
+			member.PackID = len(w.Packs)
+		}
+	}
+	
+	for _, pack := range w.Packs {
+		w.assignPackTarget(pack)
+	}
+}
+
+// assignPackTarget picks the visible rabbit closest to the pack centre and
+// gives every member its own flank cell around it, nearest members first.
+func (w *World) assignPackTarget(pack []*Fox) {
+	centre := packCentre(pack)
+	
+	var target *Position
+	bestDistance := 0
+	for _, fox := range pack {
+		seen := w.findNearestRabbit(fox.Animal.Position)
+		if seen == nil {
+			continue
+		}
+		
+		distance := abs(seen.X-centre.X) + abs(seen.Y-centre.Y)
+		if target == nil || distance < bestDistance {
+			target = seen
+			bestDistance = distance
+		}
+	}
+	
+	if target == nil {
+		return
+	}
+	
+	members := make([]*Fox, len(pack))
+	copy(members, pack)
+	sort.Slice(members, func(i, j int) bool {
+		return chebyshev(members[i].Animal.Position, *target) < chebyshev(members[j].Animal.Position, *target)
+	})
+	
+	taken := make(map[Position]bool)
+	for _, fox := range members {
+		fox.PackTarget = target
+		fox.Flank = *target
+		
+		bestFlank := -1
+		for _, offset := range packFlankOffsets {
+			flank := Position{target.X + offset.X, target.Y + offset.Y}
+			if taken[flank] || flank.X < 0 || flank.X >= w.Width || flank.Y < 0 || flank.Y >= w.Height {
+				continue
+			}
+			
+			distance := abs(flank.X-fox.Animal.Position.X) + abs(flank.Y-fox.Animal.Position.Y)
+			if bestFlank < 0 || distance < bestFlank {
+				bestFlank = distance
+				fox.Flank = flank
+			}
+		}
+		taken[fox.Flank] = true
+	}
+}
+
+// packMove returns the next cell for a fox hunting with its pack: pounce when
+// the target is in reach, otherwise close in on the assigned flank.
+func (w *World) packMove(fox *Fox) Position {
+	target := *fox.PackTarget
+	if chebyshev(fox.Animal.Position, target) <= 1 && w.Grid[target.X][target.Y] == RabbitType {
+		return target
+	}
+	return w.moveTowardsTarget(fox.Animal.Position, fox.Flank)
+}
+
+// shareKill splits part of the energy from a kill between packmates near the
+// hunter and returns what the hunter keeps.
+func (w *World) shareKill(hunter *Fox, gain int) int {
+	if !w.packHunting || hunter.PackID == 0 {
+		return gain
+	}
+	
+	partners := make([]*Fox, 0)
+	for _, fox := range w.Packs[hunter.PackID-1] {
+		if fox != hunter && fox.Animal.Energy > 0 && chebyshev(fox.Animal.Position, hunter.Animal.Position) <= foxPackRadius {
+			partners = append(partners, fox)
+		}
+	}
+	
+	if len(partners) == 0 {
+		return gain
+	}
+	
+	share := int(float64(gain) * packShareFraction / float64(len(partners)))
+	for _, fox := range partners {
+		feed(&fox.Animal, &w.FoxParams, share)
+	}
+	
+	return gain - share*len(partners)
+}
+
+func packCentre(pack []*Fox) Position {
+	sumX, sumY := 0, 0
+	for _, fox := range pack {
+		sumX += fox.Animal.Position.X
+		sumY += fox.Animal.Position.Y
+	}
+	return Position{sumX / len(pack), sumY / len(pack)}
+}
+
+func chebyshev(a, b Position) int {
+	dx := abs(a.X - b.X)
+	dy := abs(a.Y - b.Y)
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Parallel update for large grids. It follows the synchronous update: the
+// grid is cut into square tiles and worker goroutines grow the grass, decay
+// the scent and decide the moves of the animals in each tile, reading the
+// world but never changing it. Every tile draws its random numbers from its
+// own generator seeded from the world seed, the tick and the tile index, and
+// results are gathered in tile order, so a run is reproducible for a given
+// seed. Everything that crosses tile borders (conflicts over cells, births,
+// hunting) is resolved afterwards on a single goroutine.
+
+// globalSource lets code written against *rand.Rand draw from the
+// package-level generator, which the serial update keeps using.
+type globalSource struct{}
+
+func (globalSource) Int63() int64    { return rand.Int63() }
+func (globalSource) Uint64() uint64  { return rand.Uint64() }
+func (globalSource) Seed(seed int64) {}
+
+var sharedRand = rand.New(globalSource{})
+
+func (w *World) tilesAcross() int {
+	return (w.Width + parallelTileSize - 1) / parallelTileSize
+}
+
+func (w *World) tileCount() int {
+	return w.tilesAcross() * ((w.Height + parallelTileSize - 1) / parallelTileSize)
+}
+
+func (w *World) tileOf(pos Position) int {
+	return pos.X/parallelTileSize + (pos.Y/parallelTileSize)*w.tilesAcross()
+}
+
+func (w *World) tileBounds(tile int) (x0, y0, x1, y1 int) {
+	x0 = (tile % w.tilesAcross()) * parallelTileSize
+	y0 = (tile / w.tilesAcross()) * parallelTileSize
+	x1 = x0 + parallelTileSize
+	y1 = y0 + parallelTileSize
+	if x1 > w.Width {
+		x1 = w.Width
+	}
+	if y1 > w.Height {
+		y1 = w.Height
+	}
+	return x0, y0, x1, y1
+}
+
+// Phases of a tick that draw from the tile generators. Each phase gets its
+// own stream, so the grass and the moves of a tile are not correlated.
+const (
+	grassPhase = iota + 1
+	intentPhase
+)
+
+// tileRand returns the random generator for a tile in one phase of the
+// current tick.
+func (w *World) tileRand(phase, tile int) *rand.Rand {
+	seed := w.Seed ^ int64(w.Tick+1)*0x5851F42D4C957F2D ^ int64(tile+1)*0x14057B7EF767814F ^ int64(phase)*0x2545F4914F6CDD1D
+	return rand.New(rand.NewSource(seed))
+}
+
+// forEachTile calls fn for every tile using w.workers goroutines.
+func (w *World) forEachTile(fn func(tile int)) {
+	tiles := make(chan int)
+	var wg sync.WaitGroup
+	
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range tiles {
+				fn(tile)
+			}
+		}()
+	}
+	
+	for tile := 0; tile < w.tileCount(); tile++ {
+		tiles <- tile
+	}
+	close(tiles)
+	wg.Wait()
+}
+
+func (w *World) updateParallel() {
+	w.forEachTile(func(tile int) {
+		rng := w.tileRand(grassPhase, tile)
+		x0, y0, x1, y1 := w.tileBounds(tile)
+		for x := x0; x < x1; x++ {
+			for y := y0; y < y1; y++ {
+				w.decayScentAt(x, y)
+				if grass, exists := w.Grass[Position{x, y}]; exists {
+					w.growGrass(grass, rng)
+				}
+			}
+		}
+	})
+	w.spawnGrass()
+	
+	rabbits, foxes := w.ageAll()
+	
+	tileRabbits := make([][]*Rabbit, w.tileCount())
+	for _, rabbit := range rabbits {
+		tile := w.tileOf(rabbit.Animal.Position)
+		tileRabbits[tile] = append(tileRabbits[tile], rabbit)
+	}
+	tileFoxes := make([][]*Fox, w.tileCount())
+	for _, fox := range foxes {
+		tile := w.tileOf(fox.Animal.Position)
+		tileFoxes[tile] = append(tileFoxes[tile], fox)
+	}
+	
+	tileIntents := make([][]moveIntent, w.tileCount())
+	w.forEachTile(func(tile int) {
+		rng := w.tileRand(intentPhase, tile)
+		intents := make([]moveIntent, 0, len(tileRabbits[tile])+len(tileFoxes[tile]))
+		for _, rabbit := range tileRabbits[tile] {
+			intents = append(intents, w.rabbitIntent(rabbit, rng))
+		}
+		for _, fox := range tileFoxes[tile] {
+			intents = append(intents, w.foxIntent(fox, rng))
+		}
+		tileIntents[tile] = intents
+	})
+	
+	intents := make([]moveIntent, 0, len(rabbits)+len(foxes))
+	for _, tile := range tileIntents {
+		intents = append(intents, tile...)
+	}
+	
+	resolveConflicts(intents)
+	w.applyIntents(intents)
+	w.resolveActions(intents)
+}
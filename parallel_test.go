@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// fingerprint describes everything the simulation changes, in a fixed order.
+func fingerprint(w *World) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tick %d hunts %d/%d\n", w.Tick, w.HuntSuccesses, w.HuntAttempts)
+	for _, rabbit := range w.Rabbits {
+		fmt.Fprintf(&b, "rabbit %+v\n", *rabbit)
+	}
+	for _, fox := range w.Foxes {
+		fmt.Fprintf(&b, "fox %+v pack %d\n", fox.Animal, fox.PackID)
+	}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			if grass, exists := w.Grass[Position{x, y}]; exists {
+				fmt.Fprintf(&b, "grass %d,%d %d\n", x, y, grass.Amount)
+			}
+			if w.Scent[x][y] != 0 {
+				fmt.Fprintf(&b, "scent %d,%d %g\n", x, y, w.Scent[x][y])
+			}
+		}
+	}
+	return b.String()
+}
+
+// runSeeded runs a world like the game does for the given number of ticks.
+// With tiled set the parallel update is used even for a single worker.
+func runSeeded(seed int64, width, height, workers int, tiled bool, ticks int) string {
+	rand.Seed(seed)
+	w := NewWorld(width, height)
+	w.Seed = seed
+	w.workers = workers
+	w.addTestEntities()
+	
+	for i := 0; i < ticks; i++ {
+		if tiled {
+			if w.regionsEnabled {
+				w.regionCounts = w.countByRegion()
+			}
+			w.updateParallel()
+		} else {
+			w.Update()
+		}
+		w.Tick++
+	}
+	return fingerprint(w)
+}
+
+func TestRunsAreReproducible(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers [2]int // Worker counts of the two runs compared
+		tiled   bool
+	}{
+		{"serial", [2]int{1, 1}, false},
+		{"parallel", [2]int{4, 4}, false},
+		{"parallel with different worker counts", [2]int{2, 8}, false},
+		{"tiled update on one worker and in parallel", [2]int{1, 4}, true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := runSeeded(42, 150, 90, tt.workers[0], tt.tiled, 300)
+			second := runSeeded(42, 150, 90, tt.workers[1], tt.tiled, 300)
+			if first != second {
+				t.Errorf("runs with seed 42 differ")
+			}
+		})
+	}
+}
+
+func TestTileRandPhasesDiffer(t *testing.T) {
+	w := NewWorld(minGridSize, minGridSize)
+	w.Seed = 7
+	for tile := 0; tile < 4; tile++ {
+		grass, intents := w.tileRand(grassPhase, tile), w.tileRand(intentPhase, tile)
+		if grass.Int63() == intents.Int63() && grass.Int63() == intents.Int63() {
+			t.Errorf("tile %d: grass and intent phases draw the same numbers", tile)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+)
+
+// Simulation parameters that can be tuned while the simulation runs. They
+// start from the values in constants.go; every change is kept in the world's
+// parameter log so exported data records what the run actually used.
+
+type SimParams struct {
+	GrassGrowthRate  int
+	GrassSpawnChance float64
+	GrassEnergyGain  int
+	MinGrassToEat    int
+	
+	RabbitMoveChance         float64
+	RabbitEnergyLoss         int
+	ReproduceEnergyThreshold int
+	ReproductionCooldown     int
+	ReproduceChance          float64
+	
+	FoxMoveChance         float64
+	FoxEnergyLoss         int
+	RabbitEnergyGain      int
+	FoxReproduceThreshold int
+	FoxVisionRange        int
+	
+	MaxRabbits int
+	MaxFoxes   int
+}
+
+func defaultSimParams() SimParams {
+	return SimParams{
+		GrassGrowthRate:  grassGrowthRate,
+		GrassSpawnChance: grassSpawnChance,
+		GrassEnergyGain:  grassEnergyGain,
+		MinGrassToEat:    minGrassToEat,
+		
+		RabbitMoveChance:         rabbitMoveChance,
+		RabbitEnergyLoss:         rabbitEnergyLoss,
+		ReproduceEnergyThreshold: reproduceEnergyThreshold,
+		ReproductionCooldown:     reproductionCooldown,
+		ReproduceChance:          reproduceChance,
+		
+		FoxMoveChance:         foxMoveChance,
+		FoxEnergyLoss:         foxEnergyLoss,
+		RabbitEnergyGain:      rabbitEnergyGain,
+		FoxReproduceThreshold: foxReproduceThreshold,
+		FoxVisionRange:        foxVisionRange,
+		
+		MaxRabbits: maxRabbits,
+		MaxFoxes:   maxFoxes,
+	}
+}
+
+// tunable describes one parameter in the settings panel. Exactly one of
+// intField and floatField is set.
+type tunable struct {
+	name       string
+	min, max   float64
+	step       float64
+	intField   func(p *SimParams) *int
+	floatField func(p *SimParams) *float64
+}
+
+var tunables = []tunable{
+	{"Grass growth", 0, 20, 1, func(p *SimParams) *int { return &p.GrassGrowthRate }, nil},
+	{"Grass spawn", 0, 0.2, 0.005, nil, func(p *SimParams) *float64 { return &p.GrassSpawnChance }},
+	{"Grass energy", 0, 100, 5, func(p *SimParams) *int { return &p.GrassEnergyGain }, nil},
+	{"Min grass", 0, 100, 5, func(p *SimParams) *int { return &p.MinGrassToEat }, nil},
+	{"Rabbit move", 0, 1, 0.05, nil, func(p *SimParams) *float64 { return &p.RabbitMoveChance }},
+	{"Rabbit loss", 0, 10, 1, func(p *SimParams) *int { return &p.RabbitEnergyLoss }, nil},
+	{"Breed energy", 0, 100, 5, func(p *SimParams) *int { return &p.ReproduceEnergyThreshold }, nil},
+	{"Breed cooldown", 0, 600, 30, func(p *SimParams) *int { return &p.ReproductionCooldown }, nil},
+	{"Breed chance", 0, 1, 0.05, nil, func(p *SimParams) *float64 { return &p.ReproduceChance }},
+	{"Fox move", 0, 1, 0.05, nil, func(p *SimParams) *float64 { return &p.FoxMoveChance }},
+	{"Fox loss", 0, 10, 1, func(p *SimParams) *int { return &p.FoxEnergyLoss }, nil},
+	{"Fox energy", 0, 150, 5, func(p *SimParams) *int { return &p.RabbitEnergyGain }, nil},
+	{"Fox breed", 0, 150, 5, func(p *SimParams) *int { return &p.FoxReproduceThreshold }, nil},
+	{"Fox vision", 1, 10, 1, func(p *SimParams) *int { return &p.FoxVisionRange }, nil},
+	{"Max rabbits", 1, 400, 10, func(p *SimParams) *int { return &p.MaxRabbits }, nil},
+	{"Max foxes", 1, 100, 5, func(p *SimParams) *int { return &p.MaxFoxes }, nil},
+}
+
+// overriddenBy names the feature that replaces the parameter on w, or returns
+// "" when the parameter is in effect: the carrying capacity model sets its own
+// population caps and continuous metabolism replaces the flat energy loss.
+func (t *tunable) overriddenBy(w *World) string {
+	if t.intField == nil {
+		return ""
+	}
+	field := t.intField(&w.Params)
+	switch {
+	case w.carryingCapacity && (field == &w.Params.MaxRabbits || field == &w.Params.MaxFoxes):
+		return "capacity"
+	case w.continuousMetabolism && (field == &w.Params.RabbitEnergyLoss || field == &w.Params.FoxEnergyLoss):
+		return "metabolism"
+	}
+	return ""
+}
+
+func (t *tunable) value(p *SimParams) float64 {
+	if t.intField != nil {
+		return float64(*t.intField(p))
+	}
+	return *t.floatField(p)
+}
+
+// set stores v, clamped to the tunable's range and rounded to its step.
+func (t *tunable) set(p *SimParams, v float64) {
+	v = math.Round(v/t.step) * t.step
+	if v < t.min {
+		v = t.min
+	}
+	if v > t.max {
+		v = t.max
+	}
+	
+	if t.intField != nil {
+		*t.intField(p) = int(math.Round(v))
+	} else {
+		*t.floatField(p) = v
+	}
+}
+
+func (t *tunable) format(v float64) string {
+	if t.intField != nil {
+		return strconv.Itoa(int(v))
+	}
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+type ParamChange struct {
+	Tick     int
+	Name     string
+	Old, New string
+}
+
+// setParam changes a parameter on the live world and logs the change.
+func (w *World) setParam(t *tunable, v float64) {
+	old := t.value(&w.Params)
+	t.set(&w.Params, v)
+	w.logParamChange(t, old)
+}
+
+// logParamChange records that a parameter was changed from old to its
+// current value. Nothing is logged if the value ended up the same.
+func (w *World) logParamChange(t *tunable, old float64) {
+	updated := t.value(&w.Params)
+	if updated == old {
+		return
+	}
+	
+	change := ParamChange{Tick: w.Tick, Name: t.name, Old: t.format(old), New: t.format(updated)}
+	w.ParamLog = append(w.ParamLog, change)
+	log.Printf("Parameter %s: %s -> %s (tick %d)", change.Name, change.Old, change.New, change.Tick)
+}
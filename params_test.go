@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+// Every parameter in the settings panel must change the simulation, or be
+// marked as overridden, whatever features are on.
+func TestTunedGrassParamsWithRegions(t *testing.T) {
+	w := NewWorld(20, 20)
+	w.regionsEnabled = true
+	pos := Position{5, 5}
+	
+	chance := w.grassSpawnChanceAt(pos)
+	w.Params.GrassSpawnChance *= 2
+	if got := w.grassSpawnChanceAt(pos); got != 2*chance {
+		t.Errorf("spawn chance with doubled parameter = %v, want %v", got, 2*chance)
+	}
+	
+	w.Params.GrassGrowthRate = 0
+	for season := range seasonNames {
+		w.Tick = season * seasonLength
+		if got := w.grassGrowthAt(pos, sharedRand); got != 0 {
+			t.Errorf("growth in %s with rate 0 = %d, want 0", seasonNames[season], got)
+		}
+	}
+}
+
+func TestTunableOverridden(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   bool
+		metabolism bool
+		want       string
+	}{
+		{"Max rabbits", true, false, "capacity"},
+		{"Max foxes", true, false, "capacity"},
+		{"Max rabbits", false, true, ""},
+		{"Rabbit loss", false, true, "metabolism"},
+		{"Fox loss", false, true, "metabolism"},
+		{"Rabbit loss", true, false, ""},
+		{"Fox loss", false, false, ""},
+		{"Grass growth", true, true, ""},
+		{"Breed chance", true, true, ""},
+	}
+	
+	for _, tt := range tests {
+		w := NewWorld(minGridSize, minGridSize)
+		w.carryingCapacity = tt.capacity
+		w.continuousMetabolism = tt.metabolism
+		for i := range tunables {
+			if tunables[i].name == tt.name {
+				if got := tunables[i].overriddenBy(w); got != tt.want {
+					t.Errorf("%s with capacity %v, metabolism %v overridden by %q, want %q", tt.name, tt.capacity, tt.metabolism, got, tt.want)
+				}
+			}
+		}
+	}
+}
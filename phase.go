@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"strings"
+)
+
+// Phase-space view of the population history: one population plotted against
+// another as a trajectory, so predator-prey cycles show up as loops. Older
+// parts of the trajectory are drawn darker.
+
+type chartView int
+
+const (
+	viewTimeSeries chartView = iota
+	viewFoxesRabbits
+	viewRabbitsGrass
+)
+
+var chartViewNames = []string{"time series", "foxes vs rabbits", "rabbits vs grass"}
+
+// phaseAxes gives the series (indices into populationSeries) on the
+// horizontal and vertical axis of each phase view.
+var phaseAxes = map[chartView][2]int{
+	viewFoxesRabbits: {0, 1},
+	viewRabbitsGrass: {2, 0},
+}
+
+type phasePlot struct {
+	left, top, right, bottom int
+	xSeries, ySeries         int
+	xMax, yMax               int
+}
+
+func newPhasePlot(history []PopulationData, view chartView, left, top, right, bottom int) *phasePlot {
+	axes := phaseAxes[view]
+	p := &phasePlot{left: left, top: top, right: right, bottom: bottom, xSeries: axes[0], ySeries: axes[1]}
+	
+	largestX, largestY := 10, 10
+	for _, data := range history {
+		if value := populationSeries[p.xSeries].value(data); value > largestX {
+			largestX = value
+		}
+		if value := populationSeries[p.ySeries].value(data); value > largestY {
+			largestY = value
+		}
+	}
+	
+	p.xMax = roundUp(largestX, niceStep(float64(largestX), 5))
+	p.yMax = roundUp(largestY, niceStep(float64(largestY), 4))
+	return p
+}
+
+func roundUp(value int, step float64) int {
+	return int(math.Ceil(float64(value)/step) * step)
+}
+
+func (p *phasePlot) point(data PopulationData) (float32, float32) {
+	x := float64(populationSeries[p.xSeries].value(data)) / float64(p.xMax)
+	y := float64(populationSeries[p.ySeries].value(data)) / float64(p.yMax)
+	return float32(float64(p.left) + x*float64(p.right-p.left)), float32(float64(p.bottom) - y*float64(p.bottom-p.top))
+}
+
+func (p *phasePlot) inside(x, y int) bool {
+	return x >= p.left && x <= p.right && y >= p.top && y <= p.bottom
+}
+
+func (p *phasePlot) drawAxes(s surface) {
+	gridColor := color.RGBA{50, 50, 50, 255}
+	axisColor := color.RGBA{140, 140, 140, 255}
+	
+	step := niceStep(float64(p.xMax), 5)
+	for value := 0.0; value <= float64(p.xMax); value += step {
+		x := float32(float64(p.left) + value/float64(p.xMax)*float64(p.right-p.left))
+		s.line(x, float32(p.top), x, float32(p.bottom), gridColor)
+		label := fmt.Sprintf("%d", int(value))
+		s.print(label, int(x)-len(label)*3, p.bottom+2)
+	}
+	
+	step = niceStep(float64(p.yMax), 4)
+	for value := 0.0; value <= float64(p.yMax); value += step {
+		y := float32(float64(p.bottom) - value/float64(p.yMax)*float64(p.bottom-p.top))
+		s.line(float32(p.left), y, float32(p.right), y, gridColor)
+		label := fmt.Sprintf("%d", int(value))
+		s.print(label, p.left-4-len(label)*6, int(y)-8)
+	}
+	
+	s.line(float32(p.left), float32(p.top), float32(p.left), float32(p.bottom), axisColor)
+	s.line(float32(p.left), float32(p.bottom), float32(p.right), float32(p.bottom), axisColor)
+	
+	xName := populationSeries[p.xSeries].name
+	s.print(xName+" ->", p.right-len(xName)*6-18, p.bottom-16)
+	s.print("^ "+populationSeries[p.ySeries].name, p.left+4, p.top)
+}
+
+// drawTrajectory connects the recorded points in order, from dark (oldest) to
+// bright (newest), and marks the start and the current state.
+func (p *phasePlot) drawTrajectory(s surface, history []PopulationData) {
+	if len(history) < 1 {
+		return
+	}
+	
+	prevX, prevY := p.point(history[0])
+	s.fillRect(prevX-2, prevY-2, 5, 5, color.RGBA{100, 100, 100, 255})
+	for i := 1; i < len(history); i++ {
+		x, y := p.point(history[i])
+		age := float64(i) / float64(len(history)-1)
+		lineColor := color.RGBA{
+			uint8(60 + age*195),
+			uint8(60 + age*140),
+			uint8(90 - age*90),
+			255,
+		}
+		s.line(prevX, prevY, x, y, lineColor)
+		prevX, prevY = x, y
+	}
+	s.fillRect(prevX-2, prevY-2, 5, 5, color.RGBA{255, 255, 255, 255})
+}
+
+func (p *phasePlot) draw(s surface, history []PopulationData) {
+	p.drawAxes(s)
+	p.drawTrajectory(s, history)
+}
+
+// drawTooltip shows the tick and values of the recorded point nearest to the
+// cursor.
+func (p *phasePlot) drawTooltip(s surface, history []PopulationData, cursorX, cursorY int) {
+	best, bestDistance := 0, math.MaxFloat64
+	for i, data := range history {
+		x, y := p.point(data)
+		distance := math.Hypot(float64(x)-float64(cursorX), float64(y)-float64(cursorY))
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	
+	data := history[best]
+	x, y := p.point(data)
+	s.fillRect(x-3, y-3, 7, 7, color.RGBA{0, 220, 220, 255})
+	
+	text := fmt.Sprintf("Tick %d", data.Tick)
+	for _, series := range populationSeries {
+		text += fmt.Sprintf("\n%s: %d", series.name, series.value(data))
+	}
+	
+	boxWidth, boxHeight := 100, 16*(len(populationSeries)+1)+4
+	boxX := int(x) + 8
+	if boxX+boxWidth > p.right {
+		boxX = int(x) - 8 - boxWidth
+	}
+	s.fillRect(float32(boxX), float32(p.top), float32(boxWidth), float32(boxHeight), color.RGBA{30, 30, 40, 230})
+	s.print(text, boxX+4, p.top+2)
+}
+
+func (g *Game) phasePlot(history []PopulationData) *phasePlot {
+	return newPhasePlot(history, g.chartView, graphOffsetX+40, graphOffsetY+8, graphOffsetX+graphWidth-8, graphOffsetY+graphHeight-20)
+}
+
+func (g *Game) cycleChartView() {
+	g.chartView = (g.chartView + 1) % chartView(len(chartViewNames))
+	log.Printf("Chart view: %s", chartViewNames[g.chartView])
+}
+
+// savePhasePlot writes both phase views of the population history side by
+// side to an image.
+func (g *Game) savePhasePlot(timestamp string) {
+	history := g.history.downsampled(historyDisplayPoints)
+	if len(history) < 2 {
+		return
+	}
+	
+	width, height := 1000, 500
+	c := newCanvas(width, height)
+	c.fill(color.RGBA{20, 20, 20, 255})
+	
+	first := history[0].Tick
+	last := history[len(history)-1].Tick
+	c.print(fmt.Sprintf("Phase space, ticks %d-%d (dark = older, white = last)", first, last), 10, 6)
+	for i, view := range []chartView{viewFoxesRabbits, viewRabbitsGrass} {
+		left := i*width/2 + 50
+		plot := newPhasePlot(history, view, left, 40, left+width/2-80, height-30)
+		plot.draw(c, history)
+	}
+	
+	files, err := saveImage("ecosystem_phase_"+timestamp, c, g.imageFormats)
+	if err != nil {
+		log.Printf("Error saving phase plot: %v", err)
+		return
+	}
+	log.Printf("Phase plot saved: %s", strings.Join(files, ", "))
+}
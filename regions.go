@@ -0,0 +1,234 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// The grid is split into named regions with their own grass growth, spawn
+// chance and movement cost. Growth also changes with the seasons, and with
+// migration on animals walk towards the region that suits them best in the
+// current season.
+
+type Region struct {
+	Name         string
+	GrowthRate   int
+	SpawnChance  float64
+	MoveCost     float64    // Extra energy spent per move inside the region
+	SeasonGrowth [4]float64 // Growth multiplier for spring, summer, autumn, winter
+	Color        color.RGBA // Background tint on the map
+}
+
+type RegionCount struct {
+	Rabbits int
+	Foxes   int
+	Grass   int
+}
+
+const (
+	MeadowRegion = iota
+	BarrenRegion
+	ForestRegion
+)
+
+var seasonNames = []string{"Spring", "Summer", "Autumn", "Winter"}
+
+func defaultRegions() []Region {
+	return []Region{
+		{
+			Name:         "Meadow",
+			GrowthRate:   3,
+			SpawnChance:  0.02,
+			MoveCost:     0,
+			SeasonGrowth: [4]float64{1.2, 1.5, 0.6, 0.1},
+			Color:        color.RGBA{30, 40, 10, 255},
+		},
+		{
+			Name:         "Barren",
+			GrowthRate:   1,
+			SpawnChance:  0.002,
+			MoveCost:     0.01,
+			SeasonGrowth: [4]float64{0.8, 0.3, 0.8, 0.5},
+			Color:        color.RGBA{40, 30, 20, 255},
+		},
+		{
+			Name:         "Forest",
+			GrowthRate:   1,
+			SpawnChance:  0.01,
+			MoveCost:     0.02,
+			SeasonGrowth: [4]float64{0.8, 0.9, 1.0, 0.8},
+			Color:        color.RGBA{10, 25, 20, 255},
+		},
+	}
+}
+
+// generateRegions lays out the regions as Voronoi cells around random seeds,
+// giving a few irregular patches of each type.
+func (w *World) generateRegions() {
+	seeds := make([]Position, regionSeeds)
+	for i := range seeds {
+		seeds[i] = Position{rand.Intn(w.Width), rand.Intn(w.Height)}
+	}
+	
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			nearest := 0
+			bestDistance := -1
+			for i, seed := range seeds {
+				distance := (seed.X-x)*(seed.X-x) + (seed.Y-y)*(seed.Y-y)
+				if bestDistance < 0 || distance < bestDistance {
+					bestDistance = distance
+					nearest = i
+				}
+			}
+			w.RegionMap[x][y] = nearest % len(w.Regions)
+		}
+	}
+	
+	w.updateRegionDistances()
+}
+
+// updateRegionDistances computes, for every region, the number of steps from
+// each cell to the closest cell of that region. Migrating animals walk down
+// these fields. It has to be called whenever RegionMap changes.
+func (w *World) updateRegionDistances() {
+	w.regionDistance = make([][][]int, len(w.Regions))
+	
+	for r := range w.Regions {
+		distance := make([][]int, w.Width)
+		queue := make([]Position, 0)
+		for x := 0; x < w.Width; x++ {
+			distance[x] = make([]int, w.Height)
+			for y := 0; y < w.Height; y++ {
+				distance[x][y] = -1
+				if w.RegionMap[x][y] == r {
+					distance[x][y] = 0
+					queue = append(queue, Position{x, y})
+				}
+			}
+		}
+		
+		for i := 0; i < len(queue); i++ {
+			for _, next := range w.getAdjacentPositions(queue[i]) {
+				if distance[next.X][next.Y] < 0 {
+					distance[next.X][next.Y] = distance[queue[i].X][queue[i].Y] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+		
+		w.regionDistance[r] = distance
+	}
+	
+	w.RegionVersion++
+}
+
+func (w *World) regionAt(pos Position) *Region {
+	return &w.Regions[w.RegionMap[pos.X][pos.Y]]
+}
+
+func (w *World) season() int {
+	return (w.Tick / seasonLength) % len(seasonNames)
+}
+
+// grassGrowthAt returns how much grass grows on pos this tick. Fractional
+// growth is resolved randomly so that slow regions still grow on average.
+// With regions, the growth parameter scales the regional rates relative to
+// its default.
+func (w *World) grassGrowthAt(pos Position, rng *rand.Rand) int {
+	if !w.regionsEnabled {
+		return w.Params.GrassGrowthRate
+	}
+	
+	region := w.regionAt(pos)
+	growth := float64(region.GrowthRate) * region.SeasonGrowth[w.season()] * float64(w.Params.GrassGrowthRate) / grassGrowthRate
+	whole := int(growth)
+	if rng.Float64() < growth-float64(whole) {
+		whole++
+	}
+	return whole
+}
+
+func (w *World) grassSpawnChanceAt(pos Position) float64 {
+	if !w.regionsEnabled {
+		return w.Params.GrassSpawnChance
+	}
+	
+	region := w.regionAt(pos)
+	return region.SpawnChance * region.SeasonGrowth[w.season()] * w.Params.GrassSpawnChance / grassSpawnChance
+}
+
+// chargeMoveCost bills an animal for entering its current cell.
+func (w *World) chargeMoveCost(animal *Animal) {
+	if !w.regionsEnabled {
+		return
+	}
+	
+	spendEnergy(animal, w.regionAt(animal.Position).MoveCost)
+}
+
+// preferredRegion is where a species wants to be this season: rabbits go
+// where grass grows best, foxes go where the rabbits are.
+func (w *World) preferredRegion(species EntityType) int {
+	best := 0
+	bestScore := -1.0
+	
+	for r, region := range w.Regions {
+		var score float64
+		switch species {
+		case RabbitType:
+			score = float64(region.GrowthRate) * region.SeasonGrowth[w.season()]
+		case FoxType:
+			score = float64(w.regionCounts[r].Rabbits)
+		}
+		
+		if score > bestScore {
+			bestScore = score
+			best = r
+		}
+	}
+	
+	return best
+}
+
+// migrationMove returns a move towards the preferred region for the species.
+// ok is false when migration is off, the animal is already there or the
+// roll for migrating this tick fails.
+func (w *World) migrationMove(current Position, species EntityType, validMoves []Position, rng *rand.Rand) (Position, bool) {
+	if !w.regionsEnabled || !w.migration || len(validMoves) == 0 {
+		return current, false
+	}
+	
+	target := w.preferredRegion(species)
+	distance := w.regionDistance[target]
+	if distance[current.X][current.Y] == 0 || rng.Float64() >= migrationBias {
+		return current, false
+	}
+	
+	best := current
+	bestDistance := distance[current.X][current.Y]
+	for _, move := range validMoves {
+		if distance[move.X][move.Y] < bestDistance {
+			bestDistance = distance[move.X][move.Y]
+			best = move
+		}
+	}
+	
+	return best, best != current
+}
+
+func (w *World) countByRegion() []RegionCount {
+	counts := make([]RegionCount, len(w.Regions))
+	
+	for pos := range w.Grass {
+		counts[w.RegionMap[pos.X][pos.Y]].Grass++
+	}
+	for _, rabbit := range w.Rabbits {
+		counts[w.RegionMap[rabbit.Animal.Position.X][rabbit.Animal.Position.Y]].Rabbits++
+	}
+	for _, fox := range w.Foxes {
+		counts[w.RegionMap[fox.Animal.Position.X][fox.Animal.Position.Y]].Foxes++
+	}
+	
+	return counts
+}
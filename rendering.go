@@ -2,107 +2,210 @@ package main
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-func (g *Game) drawWorld(screen *ebiten.Image) {
-	for pos, grass := range g.world.Grass {
-		if pos.Y * cellSize < gameAreaHeight {
-			g.drawGrass(screen, pos, grass.Amount)
-		}
+// cellSize returns the size in pixels of one grid cell, chosen so that the
+// whole grid fits in the game area. Grids too large for one pixel per cell
+// are drawn off screen and scaled down by worldScale.
+func (g *Game) cellSize() int {
+	return fitCellSize(g.world.Width, g.world.Height)
+}
+
+func (g *Game) worldScale() float64 {
+	return fitWorldScale(g.world.Width, g.world.Height)
+}
+
+func fitCellSize(width, height int) int {
+	size := screenWidth / width
+	if fit := gameAreaHeight / height; fit < size {
+		size = fit
 	}
-	
-	for _, rabbit := range g.world.Rabbits {
-		if rabbit.Animal.Position.Y * cellSize < gameAreaHeight {
-			g.drawRabbit(screen, rabbit.Animal.Position)
-		}
+	if size > maxCellSize {
+		size = maxCellSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func fitWorldScale(width, height int) float64 {
+	size := fitCellSize(width, height)
+	scale := 1.0
+	if fit := float64(screenWidth) / float64(width*size); fit < scale {
+		scale = fit
+	}
+	if fit := float64(gameAreaHeight) / float64(height*size); fit < scale {
+		scale = fit
+	}
+	return scale
+}
+
+// cellAt returns the grid cell under a point on the screen.
+func (g *Game) cellAt(x, y int) (Position, bool) {
+	if x < 0 || y < 0 || x >= screenWidth || y >= gameAreaHeight {
+		return Position{}, false
 	}
 	
-	for _, fox := range g.world.Foxes {
-		if fox.Animal.Position.Y * cellSize < gameAreaHeight {
-			g.drawFox(screen, fox.Animal.Position)
-		}
+	pixels := float64(g.cellSize()) * g.worldScale()
+	pos := Position{int(float64(x) / pixels), int(float64(y) / pixels)}
+	if pos.X >= g.world.Width || pos.Y >= g.world.Height {
+		return Position{}, false
 	}
+	return pos, true
 }
 
-func (g *Game) drawGrassInArea(screen *ebiten.Image, pos Position, amount int) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+func (g *Game) drawWorld(screen *ebiten.Image) {
+	target := screen
+	scale := g.worldScale()
+	if scale < 1 {
+		size := g.cellSize()
+		width, height := g.world.Width*size, g.world.Height*size
+		if g.worldLayer == nil || g.worldLayer.Bounds().Dx() != width || g.worldLayer.Bounds().Dy() != height {
+			g.worldLayer = ebiten.NewImage(width, height)
+		}
+		g.worldLayer.Clear()
+		target = g.worldLayer
+	}
 	
-	if y >= gameAreaHeight {
-		return
+	if g.world.regionsEnabled {
+		g.drawRegions(target)
 	}
 	
-	// Grass color intensity based on amount (0-100)
-	intensity := uint8(50 + (amount * 205 / 100))
-	grassColor := color.RGBA{0, intensity, 0, 255}
+	for pos, grass := range g.world.Grass {
+		g.drawGrass(target, pos, grass.Amount)
+	}
 	
-	g.fillRectInArea(screen, x, y, cellSize, cellSize, grassColor)
-}
-
-func (g *Game) drawRabbitInArea(screen *ebiten.Image, pos Position) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+	if g.showScent {
+		g.drawScentOverlay(target)
+	}
 	
-	if y >= gameAreaHeight {
-		return
+	for _, rabbit := range g.world.Rabbits {
+		g.drawRabbit(target, rabbit.Animal.Position)
 	}
 	
-	var rabbit *Rabbit
-	for _, r := range g.world.Rabbits {
-		if r.Animal.Position.X == pos.X && r.Animal.Position.Y == pos.Y {
-			rabbit = r
-			break
+	for _, fox := range g.world.Foxes {
+		g.drawFox(target, fox.Animal.Position)
+		if fox.PackID > 0 {
+			g.drawPackMarker(target, fox)
 		}
 	}
 	
-	var rabbitColor color.RGBA
-	if rabbit != nil && rabbit.NewBorn > 0 {
-		rabbitColor = color.RGBA{255, 255, 0, 255} // Yellow for newborns
-	} else {
-		rabbitColor = color.RGBA{255, 255, 255, 255} // White
-	}
+	g.drawSelection(target)
 	
-	g.fillRectInArea(screen, x+3, y+3, cellSize-6, cellSize-6, rabbitColor)
+	if target != screen {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(scale, scale)
+		op.Filter = ebiten.FilterLinear
+		screen.DrawImage(target, op)
+	}
 }
 
-func (g *Game) drawFoxInArea(screen *ebiten.Image, pos Position) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+// drawRegions tints the background by region. The tint is rendered once into
+// a cached layer and redrawn only when the region map changes.
+func (g *Game) drawRegions(screen *ebiten.Image) {
+	size := g.cellSize()
+	width, height := g.world.Width*size, g.world.Height*size
+	if g.regionLayer == nil || g.regionLayer.Bounds().Dx() != width || g.regionLayer.Bounds().Dy() != height {
+		g.regionLayer = ebiten.NewImage(width, height)
+		g.regionLayerVersion = -1
+	}
 	
-	if y >= gameAreaHeight {
-		return
+	if g.regionLayerVersion != g.world.RegionVersion {
+		g.regionLayer.Clear()
+		
+		for x := 0; x < g.world.Width; x++ {
+			for y := 0; y < g.world.Height; y++ {
+				region := g.world.Regions[g.world.RegionMap[x][y]]
+				g.fillRect(g.regionLayer, x*size, y*size, size, size, region.Color)
+			}
+		}
+		g.regionLayerVersion = g.world.RegionVersion
 	}
 	
-	foxColor := color.RGBA{255, 0, 0, 255}
-	g.fillRectInArea(screen, x+1, y+1, cellSize-2, cellSize-2, foxColor)
+	screen.DrawImage(g.regionLayer, nil)
 }
 
-func (g *Game) fillRectInArea(screen *ebiten.Image, x, y, width, height int, c color.Color) {
-	rect := ebiten.NewImage(width, height)
-	rect.Fill(c)
+var packColors = []color.RGBA{
+	{255, 200, 0, 255},
+	{0, 200, 255, 255},
+	{255, 0, 255, 255},
+	{255, 140, 80, 255},
+	{160, 255, 160, 255},
+}
+
+// drawPackMarker outlines a fox in its pack's colour and marks its flank
+// cell when the pack has a target.
+func (g *Game) drawPackMarker(screen *ebiten.Image, fox *Fox) {
+	size := g.cellSize()
+	x := fox.Animal.Position.X * size
+	y := fox.Animal.Position.Y * size
+	packColor := packColors[(fox.PackID-1)%len(packColors)]
 	
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(x), float64(y))
+	g.fillRect(screen, x, y, size, 1, packColor)
+	g.fillRect(screen, x, y+size-1, size, 1, packColor)
+	g.fillRect(screen, x, y, 1, size, packColor)
+	g.fillRect(screen, x+size-1, y, 1, size, packColor)
 	
-	screen.DrawImage(rect, op)
+	if fox.PackTarget != nil {
+		g.fillRect(screen, fox.Flank.X*size+size/2-1, fox.Flank.Y*size+size/2-1, 2, 2, packColor)
+	}
+}
+
+func (g *Game) drawScentOverlay(screen *ebiten.Image) {
+	size := g.cellSize()
+	for x := 0; x < g.world.Width; x++ {
+		for y := 0; y < g.world.Height; y++ {
+			strength := g.world.Scent[x][y]
+			if strength < scentThreshold {
+				continue
+			}
+			g.fillRect(screen, x*size, y*size, size, size, scentColor(strength))
+		}
+	}
+}
+
+// grassColor is brighter the more grass there is (0-100).
+func grassColor(amount int) color.RGBA {
+	return color.RGBA{0, uint8(50 + amount*205/100), 0, 255}
+}
+
+// rabbitColor is yellow for newborns and white otherwise.
+func rabbitColor(rabbit *Rabbit) color.RGBA {
+	if rabbit != nil && rabbit.NewBorn > 0 {
+		return color.RGBA{255, 255, 0, 255}
+	}
+	return color.RGBA{255, 255, 255, 255}
+}
+
+var foxColor = color.RGBA{255, 0, 0, 255}
+
+// scentColor is a heat colour from dim purple (faint) to bright orange
+// (fresh); components are premultiplied by alpha.
+func scentColor(strength float64) color.RGBA {
+	t := strength / maxScent
+	alpha := 60 + t*140
+	r := (120 + t*135) * alpha / 255
+	g := (40 + t*110) * alpha / 255
+	b := (160 - t*160) * alpha / 255
+	return color.RGBA{uint8(r), uint8(g), uint8(b), uint8(alpha)}
 }
 
 func (g *Game) drawGrass(screen *ebiten.Image, pos Position, amount int) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+	size := g.cellSize()
+	x := pos.X * size
+	y := pos.Y * size
 	
-	// Grass color intensity based on amount (0-100)
-	intensity := uint8(50 + (amount * 205 / 100))
-	grassColor := color.RGBA{0, intensity, 0, 255}
-	
-	g.fillRect(screen, x, y, cellSize, cellSize, grassColor)
+	g.fillRect(screen, x, y, size, size, grassColor(amount))
 }
 
 func (g *Game) drawRabbit(screen *ebiten.Image, pos Position) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+	size := g.cellSize()
+	x := pos.X * size
+	y := pos.Y * size
 	
 	var rabbit *Rabbit
 	for _, r := range g.world.Rabbits {
@@ -112,23 +215,18 @@ func (g *Game) drawRabbit(screen *ebiten.Image, pos Position) {
 		}
 	}
 	
-	var rabbitColor color.RGBA
-	if rabbit != nil && rabbit.NewBorn > 0 {
-		rabbitColor = color.RGBA{255, 255, 0, 255} // Yellow for newborns
-	} else {
-		rabbitColor = color.RGBA{255, 255, 255, 255} // White
-	}
-	
 	// Smaller rabbit so we can see grass underneath
-	g.fillRect(screen, x+3, y+3, cellSize-6, cellSize-6, rabbitColor)
+	inset := size * 3 / 10
+	g.fillRect(screen, x+inset, y+inset, size-2*inset, size-2*inset, rabbitColor(rabbit))
 }
 
 func (g *Game) drawFox(screen *ebiten.Image, pos Position) {
-	x := pos.X * cellSize
-	y := pos.Y * cellSize
+	size := g.cellSize()
+	x := pos.X * size
+	y := pos.Y * size
 	
-	foxColor := color.RGBA{255, 0, 0, 255}
-	g.fillRect(screen, x+1, y+1, cellSize-2, cellSize-2, foxColor)
+	inset := size / 10
+	g.fillRect(screen, x+inset, y+inset, size-2*inset, size-2*inset, foxColor)
 }
 
 func (g *Game) fillRect(screen *ebiten.Image, x, y, width, height int, c color.Color) {
@@ -142,122 +240,62 @@ func (g *Game) fillRect(screen *ebiten.Image, x, y, width, height int, c color.C
 }
 
 func (g *Game) drawLine(screen *ebiten.Image, x1, y1, x2, y2 int, lineColor color.RGBA) {
-	steps := 5
-	if steps <= 0 {
-		steps = 1
-	}
+	strokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), lineColor)
+}
+
+// pixel is a white 1x1 image that is stretched and tinted to draw lines and
+// rectangles without allocating an image for each.
+var pixel = func() *ebiten.Image {
+	img := ebiten.NewImage(1, 1)
+	img.Fill(color.White)
+	return img
+}()
+
+// strokeLine draws a one pixel wide line.
+func strokeLine(screen *ebiten.Image, x1, y1, x2, y2 float32, lineColor color.Color) {
+	dx, dy := float64(x2-x1), float64(y2-y1)
 	
-	for i := 0; i <= steps; i++ {
-		if steps > 0 {
-			x := x1 + ((x2-x1)*i)/steps
-			y := y1 + ((y2-y1)*i)/steps
-			g.fillRect(screen, x, y, 1, 1, lineColor)
-		}
-	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(math.Max(math.Hypot(dx, dy), 1), 1)
+	op.GeoM.Rotate(math.Atan2(dy, dx))
+	op.GeoM.Translate(float64(x1), float64(y1))
+	op.ColorScale.ScaleWithColor(lineColor)
+	screen.DrawImage(pixel, op)
+}
+
+func fillRectF(screen *ebiten.Image, x, y, width, height float32, c color.Color) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(width), float64(height))
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(c)
+	screen.DrawImage(pixel, op)
 }
 
 func (g *Game) drawPopulationGraph(screen *ebiten.Image) {
-	if len(g.populationHistory) < 1 {
+	g.drawGraph(screenSurface{screen})
+}
+
+// drawGraph draws the chart of the current view, on the screen or on a
+// canvas.
+func (g *Game) drawGraph(s surface) {
+	history := g.history.downsampled(historyDisplayPoints)
+	if len(history) < 1 {
 		return
 	}
 	
-	g.fillRect(screen, graphOffsetX, graphOffsetY, graphWidth, graphHeight, color.RGBA{20, 20, 20, 255})
-	
-	g.fillRect(screen, graphOffsetX, graphOffsetY, graphWidth, 2, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX, graphOffsetY+graphHeight-2, graphWidth, 2, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
-	g.fillRect(screen, graphOffsetX+graphWidth-2, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
-	
-	dataToUse := g.populationHistory
-	if len(dataToUse) > 50 {
-		step := len(dataToUse) / 50
-		simplified := make([]PopulationData, 0, 50)
-		for i := 0; i < len(dataToUse); i += step {
-			simplified = append(simplified, dataToUse[i])
-		}
-		dataToUse = simplified
-	}
-	
-	if len(dataToUse) < 1 {
+	g.drawGraphFrame(s)
+	if g.chartView != viewTimeSeries {
+		g.phasePlot(history).draw(s, history)
 		return
 	}
-	
-	maxValue := 20
-	for _, data := range dataToUse {
-		if data.Rabbits > maxValue {
-			maxValue = data.Rabbits
-		}
-		if data.Foxes > maxValue {
-			maxValue = data.Foxes
-		}
-		if data.Grass/10 > maxValue {
-			maxValue = data.Grass / 10
-		}
-	}
-	
-	g.drawPopulationPoints(screen, dataToUse, "rabbits", maxValue, color.RGBA{255, 255, 255, 255})
-	g.drawPopulationPoints(screen, dataToUse, "foxes", maxValue, color.RGBA{255, 0, 0, 255})
-	g.drawPopulationPoints(screen, dataToUse, "grass", maxValue, color.RGBA{0, 255, 0, 255})
+	g.populationChart(history).draw(s, history)
 }
 
-func (g *Game) drawPopulationPoints(screen *ebiten.Image, history []PopulationData, populationType string, maxValue int, pointColor color.RGBA) {
-	if len(history) < 1 || maxValue <= 0 {
-		return
-	}
+func (g *Game) drawGraphFrame(s surface) {
+	s.fillRect(graphOffsetX, graphOffsetY, graphWidth, graphHeight, color.RGBA{20, 20, 20, 255})
 	
-	var yOffset int
-	switch populationType {
-	case "rabbits":
-		yOffset = 0
-	case "foxes":
-		yOffset = -3
-	case "grass":
-		yOffset = 3
-	}
-	
-	for i, data := range history {
-		var value int
-		switch populationType {
-		case "rabbits":
-			value = data.Rabbits
-		case "foxes":
-			value = data.Foxes
-		case "grass":
-			value = data.Grass / 10
-		default:
-			continue
-		}
-		
-		if value == 0 {
-			continue
-		}
-		
-		x := graphOffsetX + 5
-		if len(history) > 1 {
-			x = graphOffsetX + 5 + ((i * (graphWidth - 10)) / (len(history) - 1))
-		}
-		
-		y := graphOffsetY + graphHeight - 5 - ((value * (graphHeight - 10)) / maxValue) + yOffset
-		if y < graphOffsetY + 5 {
-			y = graphOffsetY + 5
-		}
-		if y > graphOffsetY + graphHeight - 5 {
-			y = graphOffsetY + graphHeight - 5
-		}
-		
-		switch populationType {
-		case "rabbits":
-			g.fillRect(screen, x-1, y-1, 3, 3, pointColor)
-		case "foxes":
-			// Diamond shape
-			g.fillRect(screen, x, y-1, 1, 1, pointColor)
-			g.fillRect(screen, x-1, y, 1, 1, pointColor)
-			g.fillRect(screen, x+1, y, 1, 1, pointColor)
-			g.fillRect(screen, x, y+1, 1, 1, pointColor)
-		case "grass":
-			// Cross shape
-			g.fillRect(screen, x-1, y, 3, 1, pointColor)
-			g.fillRect(screen, x, y-1, 1, 3, pointColor)
-		}
-	}
+	s.fillRect(graphOffsetX, graphOffsetY, graphWidth, 2, color.RGBA{100, 100, 100, 255})
+	s.fillRect(graphOffsetX, graphOffsetY+graphHeight-2, graphWidth, 2, color.RGBA{100, 100, 100, 255})
+	s.fillRect(graphOffsetX, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
+	s.fillRect(graphOffsetX+graphWidth-2, graphOffsetY, 2, graphHeight, color.RGBA{100, 100, 100, 255})
 }
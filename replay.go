@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Viewer for exported CSV and columnar files, started with -view. Each file is loaded back
+// into a population history and drawn in the population chart. With several
+// files, one series of the current chart group is overlaid for all of them so
+// runs can be compared. The history animation of a file can be written again
+// without re-running the simulation; it shows only the chart, as the world is
+// not in the file.
+
+// replayRun is one loaded export.
+type replayRun struct {
+	filename string
+	header   []string    // Metadata lines of the # header, without the "# "
+	meta     RunMetadata // What could be read back from the header
+	history  *populationHistory
+}
+
+// replayColors tell the runs apart when they are overlaid.
+var replayColors = []color.RGBA{
+	{255, 255, 255, 255},
+	{255, 80, 80, 255},
+	{80, 220, 80, 255},
+	{80, 160, 255, 255},
+	{255, 200, 0, 255},
+	{255, 0, 255, 255},
+	{0, 220, 220, 255},
+	{255, 140, 80, 255},
+}
+
+// replayField stores one CSV field in a sample.
+type replayField func(data *PopulationData, value string) error
+
+func intField(field func(data *PopulationData) *int) replayField {
+	return func(data *PopulationData, value string) error {
+		n, err := strconv.Atoi(value)
+		*field(data) = n
+		return err
+	}
+}
+
+func floatField(field func(data *PopulationData) *float64) replayField {
+	return func(data *PopulationData, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		*field(data) = f
+		return err
+	}
+}
+
+// replayFields maps the column names written by sampleColumns for the given
+// regions to the sample fields they came from. Unknown columns are ignored, so
+// files from older and newer versions load with whatever columns they share.
+func replayFields(regions []Region) map[string]replayField {
+	fields := map[string]replayField{
+		"Tick":          intField(func(data *PopulationData) *int { return &data.Tick }),
+		"Rabbits":       intField(func(data *PopulationData) *int { return &data.Rabbits }),
+		"Foxes":         intField(func(data *PopulationData) *int { return &data.Foxes }),
+		"Grass":         intField(func(data *PopulationData) *int { return &data.Grass }),
+		"HuntAttempts":  intField(func(data *PopulationData) *int { return &data.HuntAttempts }),
+		"HuntSuccesses": intField(func(data *PopulationData) *int { return &data.HuntSuccesses }),
+		"GrassBiomass":  intField(func(data *PopulationData) *int { return &data.GrassBiomass }),
+		
+		"RabbitBirths":   intField(func(data *PopulationData) *int { return &data.RecentEvents.RabbitBirths }),
+		"FoxBirths":      intField(func(data *PopulationData) *int { return &data.RecentEvents.FoxBirths }),
+		"RabbitsEaten":   intField(func(data *PopulationData) *int { return &data.RecentEvents.RabbitsEaten }),
+		"RabbitsStarved": intField(func(data *PopulationData) *int { return &data.RecentEvents.RabbitsStarved }),
+		"FoxesStarved":   intField(func(data *PopulationData) *int { return &data.RecentEvents.FoxesStarved }),
+		"Hunts":          intField(func(data *PopulationData) *int { return &data.Hunts }),
+	}
+	
+	for r, region := range regions {
+		count := func(data *PopulationData) *RegionCount {
+			for len(data.Regions) <= r {
+				data.Regions = append(data.Regions, RegionCount{})
+			}
+			return &data.Regions[r]
+		}
+		fields[region.Name+"_Rabbits"] = intField(func(data *PopulationData) *int { return &count(data).Rabbits })
+		fields[region.Name+"_Foxes"] = intField(func(data *PopulationData) *int { return &count(data).Foxes })
+		fields[region.Name+"_Grass"] = intField(func(data *PopulationData) *int { return &count(data).Grass })
+	}
+	
+	for _, species := range []struct {
+		name  string
+		stats func(data *PopulationData) *SpeciesStats
+	}{
+		{"Rabbit", func(data *PopulationData) *SpeciesStats { return &data.RabbitStats }},
+		{"Fox", func(data *PopulationData) *SpeciesStats { return &data.FoxStats }},
+	} {
+		stats := species.stats
+		fields[species.name+"_MeanEnergy"] = floatField(func(data *PopulationData) *float64 { return &stats(data).MeanEnergy })
+		fields[species.name+"_MinEnergy"] = intField(func(data *PopulationData) *int { return &stats(data).MinEnergy })
+		fields[species.name+"_MaxEnergy"] = intField(func(data *PopulationData) *int { return &stats(data).MaxEnergy })
+		fields[species.name+"_MeanAge"] = floatField(func(data *PopulationData) *float64 { return &stats(data).MeanAge })
+		fields[species.name+"_MinAge"] = intField(func(data *PopulationData) *int { return &stats(data).MinAge })
+		fields[species.name+"_MaxAge"] = intField(func(data *PopulationData) *int { return &stats(data).MaxAge })
+	}
+	return fields
+}
+
+// loadReplayCSV reads a file written by writeCSV. The sample interval and
+// the regions come from the # header. Files from before the metadata header
+// and the full timestamps are read too: their interval is taken from the
+// first two samples, their regions are the default ones and their time of day
+// is put on the date of the "Generated" line.
+func loadReplayCSV(filename string) (*replayRun, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	run := &replayRun{filename: filename}
+	var columns []replayField
+	timestampColumn := -1
+	var samples []PopulationData
+	
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		
+		if strings.HasPrefix(text, "#") {
+			text = strings.TrimSpace(strings.TrimPrefix(text, "#"))
+			if text == "" {
+				continue
+			}
+			run.header = append(run.header, text)
+			continue
+		}
+		
+		values := strings.Split(text, ",")
+		if columns == nil {
+			run.meta = parseMetadataHeader(run.header)
+			regions := run.meta.Regions
+			if len(regions) == 0 {
+				regions = defaultRegions()
+			}
+			fields := replayFields(regions)
+			columns = make([]replayField, len(values))
+			hasTick := false
+			for i, name := range values {
+				columns[i] = fields[name]
+				switch name {
+				case "Tick":
+					hasTick = true
+				case "Timestamp":
+					timestampColumn = i
+				}
+			}
+			if !hasTick {
+				return nil, fmt.Errorf("%s: no Tick column", filename)
+			}
+			continue
+		}
+		
+		var data PopulationData
+		for i, value := range values {
+			if i >= len(columns) {
+				break
+			}
+			if i == timestampColumn {
+				if data.Time, err = parseReplayTime(value, run.meta.Generated); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+				}
+				continue
+			}
+			if columns[i] == nil {
+				continue
+			}
+			if err := columns[i](&data, value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+			}
+		}
+		samples = append(samples, data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no samples", filename)
+	}
+	
+	interval := run.meta.SampleInterval
+	if interval <= 0 {
+		interval = defaultSampleInterval
+		if len(samples) > 1 && samples[1].Tick > samples[0].Tick {
+			interval = samples[1].Tick - samples[0].Tick
+		}
+	}
+	run.setSamples(interval, samples)
+	return run, nil
+}
+
+// loadReplayColumnar reads a file written by writeColumnar. The values go
+// through the same field parsers as the CSV columns.
+func loadReplayColumnar(filename string) (*replayRun, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	meta, tables, err := readColumnar(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	table := tables["samples"]
+	if table == nil || table.rows == 0 {
+		return nil, fmt.Errorf("%s: no samples", filename)
+	}
+	
+	fields := replayFields(meta.Regions)
+	samples := make([]PopulationData, table.rows)
+	for _, column := range table.columns {
+		field := fields[column.name]
+		for i := range samples {
+			if column.name == "Timestamp" {
+				if samples[i].Time, err = parseReplayTime(column.text(i), meta.Generated); err != nil {
+					return nil, fmt.Errorf("%s: sample %d: %v", filename, i, err)
+				}
+				continue
+			}
+			if field == nil {
+				break
+			}
+			if err := field(&samples[i], column.text(i)); err != nil {
+				return nil, fmt.Errorf("%s: sample %d, %s: %v", filename, i, column.name, err)
+			}
+		}
+	}
+	
+	run := &replayRun{filename: filename, header: metadataHeader(meta, filepath.Base(filename)), meta: meta}
+	run.setSamples(meta.SampleInterval, samples)
+	return run, nil
+}
+
+// setSamples puts loaded samples into the run's history. The files have the
+// events since the previous sample; the running totals are rebuilt from them.
+func (r *replayRun) setSamples(interval int, samples []PopulationData) {
+	r.history = newPopulationHistory(interval)
+	r.history.started = samples[0].Time
+	var events PopulationEvents
+	for _, data := range samples {
+		events = PopulationEvents{
+			RabbitBirths:   events.RabbitBirths + data.RecentEvents.RabbitBirths,
+			FoxBirths:      events.FoxBirths + data.RecentEvents.FoxBirths,
+			RabbitsEaten:   events.RabbitsEaten + data.RecentEvents.RabbitsEaten,
+			RabbitsStarved: events.RabbitsStarved + data.RecentEvents.RabbitsStarved,
+			FoxesStarved:   events.FoxesStarved + data.RecentEvents.FoxesStarved,
+		}
+		data.Events = events
+		r.history.add(data)
+	}
+}
+
+// parseMetadataHeader reads back the lines written by metadataHeader. Lines it
+// does not know, or that an older version wrote differently, are skipped, so
+// headers from any version give whatever fields they share with this one.
+// Older headers give the sample interval on a line of its own, as "Data
+// recorded every 5 seconds (30 ticks)".
+func parseMetadataHeader(lines []string) RunMetadata {
+	meta := RunMetadata{
+		Features:  map[string]bool{},
+		Params:    defaultSimParams(),
+		Constants: map[string]float64{},
+	}
+	params := map[string]*tunable{}
+	for i := range tunables {
+		params[tunables[i].name] = &tunables[i]
+	}
+	
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			if _, ticks, found := strings.Cut(line, "Data recorded every "); found {
+				_, ticks, _ = strings.Cut(ticks, "(")
+				var interval int
+				if _, err := fmt.Sscanf(ticks, "%d ticks", &interval); err == nil {
+					meta.SampleInterval = interval
+				}
+			}
+			continue
+		}
+		switch {
+		case key == "Program":
+			name, rest, _ := strings.Cut(value, " ")
+			if open := strings.LastIndex(rest, " ("); open >= 0 && strings.HasSuffix(rest, ")") {
+				meta.Program, meta.Version, meta.GoVersion = name, rest[:open], rest[open+2:len(rest)-1]
+			}
+		case key == "Command line":
+			meta.Args = strings.Fields(value)
+		case key == "Started":
+			meta.Started, _ = parseReplayTime(value, time.Time{})
+		case key == "Generated":
+			meta.Generated, _ = parseReplayTime(value, time.Time{})
+		case key == "Duration":
+			fmt.Sscanf(value, "%d ticks (%d data points, one every %d ticks)", &meta.Ticks, &meta.Samples, &meta.SampleInterval)
+		case key == "- Seed":
+			fmt.Sscanf(value, "%d", &meta.Seed)
+		case key == "- Grid size":
+			fmt.Sscanf(value, "%dx%d", &meta.GridWidth, &meta.GridHeight)
+		case key == "- Workers":
+			fmt.Sscanf(value, "%d (tile size %d)", &meta.Workers, &meta.TileSize)
+		case strings.HasPrefix(key, "- Feature "):
+			if on, err := strconv.ParseBool(value); err == nil {
+				meta.Features[strings.TrimPrefix(key, "- Feature ")] = on
+			}
+		case strings.HasPrefix(key, "- Changed at tick "):
+			if change, err := parseParamChange(strings.TrimPrefix(key, "- Changed at tick "), value); err == nil {
+				meta.ParamLog = append(meta.ParamLog, change)
+			}
+		case strings.HasPrefix(key, "- Region "):
+			// Kept even without the seasons older headers lack: the name is
+			// what the region columns need
+			region := Region{Name: strings.TrimPrefix(key, "- Region ")}
+			g := &region.SeasonGrowth
+			fmt.Sscanf(value, "growth %d, spawn chance %g, move cost %g, seasons [%g %g %g %g]",
+				&region.GrowthRate, &region.SpawnChance, &region.MoveCost, &g[0], &g[1], &g[2], &g[3])
+			meta.Regions = append(meta.Regions, region)
+		case strings.HasSuffix(key, " metabolism"):
+			p := meta.speciesParams(strings.TrimSuffix(strings.TrimPrefix(key, "- "), " metabolism"))
+			fmt.Sscanf(value, "max energy %d, idle %g, move %g, chase %g, reproduce %g, digest %g x %d ticks",
+				&p.MaxEnergy, &p.IdleCost, &p.MoveCost, &p.ChaseCost, &p.ReproduceCost, &p.DigestCost, &p.DigestTicks)
+		case strings.HasSuffix(key, " gestation"):
+			p := meta.speciesParams(strings.TrimSuffix(strings.TrimPrefix(key, "- "), " gestation"))
+			ticks, weights, _ := strings.Cut(value, " ticks, litter size weights ")
+			p.GestationTicks, _ = strconv.Atoi(ticks)
+			p.LitterSizes, _ = parseFloatList(weights)
+		case strings.Contains(key, " at tick "):
+			kind, tick, _ := strings.Cut(strings.TrimPrefix(key, "- "), " at tick ")
+			if n, err := strconv.Atoi(tick); err == nil {
+				meta.ChangeLog = append(meta.ChangeLog, RunChange{Tick: n, Kind: kind, Detail: value})
+			}
+		case strings.HasPrefix(key, "- "):
+			name := strings.TrimPrefix(key, "- ")
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				break
+			}
+			if t, ok := params[name]; ok {
+				if t.intField != nil {
+					*t.intField(&meta.Params) = int(v)
+				} else {
+					*t.floatField(&meta.Params) = v
+				}
+			} else {
+				meta.Constants[name] = v
+			}
+		}
+	}
+	return meta
+}
+
+// speciesParams returns the parameters of the species named in the header.
+func (m *RunMetadata) speciesParams(species string) *SpeciesParams {
+	if species == "Fox" {
+		return &m.FoxParams
+	}
+	return &m.RabbitParams
+}
+
+// parseParamChange reads "name old -> new" of a parameter change at a tick.
+// Parameter names may contain spaces, values do not.
+func parseParamChange(tick, value string) (ParamChange, error) {
+	var change ParamChange
+	var err error
+	if change.Tick, err = strconv.Atoi(tick); err != nil {
+		return change, err
+	}
+	before, after, ok := strings.Cut(value, " -> ")
+	space := strings.LastIndex(before, " ")
+	if !ok || space < 0 {
+		return change, fmt.Errorf("bad parameter change %q", value)
+	}
+	change.Name, change.Old, change.New = before[:space], before[space+1:], after
+	return change, nil
+}
+
+// parseFloatList reads a slice printed with %v, such as "[1 2.5 3]".
+func parseFloatList(value string) ([]float64, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("bad list %q", value)
+	}
+	var list []float64
+	for _, field := range strings.Fields(value[1 : len(value)-1]) {
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// parseReplayTime parses an exported timestamp. Older exports wrote only the
+// time of day, or a plain date and time in the header.
+func parseReplayTime(value string, date time.Time) (time.Time, error) {
+	if t, err := time.Parse(timestampFormat, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("15:04:05", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad timestamp %q", value)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+}
+
+// name returns the file name without the directory, the ecosystem_data_
+// prefix and the extension; for exported files that is the timestamp.
+func (r *replayRun) name() string {
+	name := strings.TrimSuffix(filepath.Base(r.filename), filepath.Ext(r.filename))
+	return strings.TrimPrefix(name, "ecosystem_data_")
+}
+
+type replayViewer struct {
+	runs    []*replayRun
+	current int // Run whose header is shown and whose frames S writes
+	series  int // Series of the chart group overlaid when comparing runs
+	
+	// g holds the chart settings and draws the views of a single run
+	g *Game
+}
+
+// runReplayViewer loads the given CSV or columnar (.col) files and opens the viewer, or with
+// framesOnly writes the history animation of each file without opening a
+// window.
+func runReplayViewer(filenames []string, framesOnly bool, animation animationOptions) error {
+	v := &replayViewer{g: &Game{animation: animation}}
+	for _, filename := range filenames {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		load := loadReplayCSV
+		if filepath.Ext(filename) == ".col" {
+			load = loadReplayColumnar
+		}
+		run, err := load(filename)
+		if err != nil {
+			return err
+		}
+		log.Printf("Loaded %s: %d samples covering %d ticks", filename, run.history.len(), run.history.last().Tick)
+		v.runs = append(v.runs, run)
+	}
+	if len(v.runs) == 0 {
+		return fmt.Errorf("no files to view")
+	}
+	defer func() {
+		for _, run := range v.runs {
+			run.history.close()
+		}
+	}()
+	
+	if framesOnly {
+		for _, run := range v.runs {
+			v.saveFrames(run)
+		}
+		return nil
+	}
+	
+	ebiten.SetWindowSize(screenWidth, screenHeight)
+	ebiten.SetWindowTitle("Ecosystem Simulation - Data Viewer")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	return ebiten.RunGame(v)
+}
+
+// saveFrames writes the history animation of one run to
+// ecosystem_history_<name>_replay.
+func (v *replayViewer) saveFrames(run *replayRun) {
+	v.g.history = run.history
+	v.g.saveHistorySequence(run.name() + "_replay")
+}
+
+func (v *replayViewer) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		v.current = (v.current + 1) % len(v.runs)
+		log.Printf("Viewing %s", v.runs[v.current].filename)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+		v.g.cycleChartGroup()
+		v.series = 0
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		series := chartGroups[v.g.chartGroup].series
+		v.series = (v.series + 1) % len(series)
+		log.Printf("Compared series: %s", series[v.series].name)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		v.g.cycleChartScale()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		v.g.cycleChartView()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		v.saveFrames(v.runs[v.current])
+	}
+	return nil
+}
+
+func (v *replayViewer) Draw(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0, 0, 0, 255})
+	
+	run := v.runs[v.current]
+	text := fmt.Sprintf("Data Viewer - %s (%d/%d)\n", run.filename, v.current+1, len(v.runs))
+	for i, line := range run.header {
+		if i >= 20 {
+			text += fmt.Sprintf("... %d more header lines\n", len(run.header)-i)
+			break
+		}
+		text += line + "\n"
+	}
+	text += "Controls: TAB=Next file D=Chart series W=Compared series A=Chart scale Q=Chart view S=Save animation"
+	ebitenutil.DebugPrint(screen, text)
+	
+	if len(v.runs) > 1 {
+		v.drawRunList(screen)
+	}
+	
+	s := screenSurface{screen}
+	v.g.drawGraphFrame(s)
+	history := run.history.downsampled(historyDisplayPoints)
+	if v.g.chartView != viewTimeSeries {
+		v.g.phasePlot(history).draw(s, history)
+		text := fmt.Sprintf("Phase space: %s of %s (dark = older, white = last)", chartViewNames[v.g.chartView], run.name())
+		s.print(text, 30, screenHeight-22)
+		return
+	}
+	
+	x, y := ebiten.CursorPosition()
+	if len(v.runs) == 1 {
+		chart := v.g.populationChart(history)
+		chart.draw(s, history)
+		chart.drawLegend(s, chartGroups[v.g.chartGroup].name, 30, screenHeight-22)
+		if chart.inside(x, y) {
+			chart.drawTooltip(s, history, x)
+		}
+		return
+	}
+	
+	histories := make([][]PopulationData, len(v.runs))
+	for i, run := range v.runs {
+		histories[i] = run.history.downsampled(historyDisplayPoints)
+	}
+	chart := v.overlayChart(histories)
+	chart.drawAxes(s)
+	for i, history := range histories {
+		chart.drawSeries(s, history, i)
+	}
+	series := chartGroups[v.g.chartGroup].series[v.series]
+	chart.drawLegend(s, series.name, 30, screenHeight-22)
+}
+
+// overlayChart lays out a chart with one series per run, all showing the
+// compared series, over the tick range covering every run.
+func (v *replayViewer) overlayChart(histories [][]PopulationData) *populationChart {
+	compared := chartGroups[v.g.chartGroup].series[v.series]
+	series := make([]chartSeries, len(histories))
+	var all []PopulationData
+	firstTick, lastTick := histories[0][0].Tick, histories[0][0].Tick
+	for i, history := range histories {
+		series[i] = chartSeries{fmt.Sprintf("#%d", i+1), replayColors[i%len(replayColors)], compared.value}
+		all = append(all, history...)
+		firstTick = min(firstTick, history[0].Tick)
+		lastTick = max(lastTick, history[len(history)-1].Tick)
+	}
+	
+	chart := newPopulationChart(all, series, firstTick, lastTick, v.g.chartScale)
+	
+	// Each run is scaled to its own maximum in the per-series scale
+	for i, history := range histories {
+		chart.max[i] = 0
+		for _, data := range history {
+			chart.max[i] = max(chart.max[i], compared.value(data))
+		}
+	}
+	return chart
+}
+
+// drawRunList lists the loaded files with their colours on the right.
+func (v *replayViewer) drawRunList(screen *ebiten.Image) {
+	x, y := 520, 10
+	for i, run := range v.runs {
+		fillRectF(screen, float32(x), float32(y+4), 10, 10, replayColors[i%len(replayColors)])
+		label := fmt.Sprintf("#%d %s", i+1, run.name())
+		if i == v.current {
+			label = "> " + label
+		}
+		ebitenutil.DebugPrintAt(screen, label, x+14, y)
+		y += 16
+	}
+}
+
+func (v *replayViewer) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return screenWidth, screenHeight
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseMetadataHeader(t *testing.T) {
+	history, world := exportedRun()
+	world.Seed = 42
+	world.workers = 4
+	world.Params.GrassGrowthRate = 3
+	world.Params.ReproduceChance = 0.35
+	world.Regions[0].SeasonGrowth = [4]float64{1.5, 1, 0.5, 0.25}
+	meta := runMetadata(history, world)
+	meta.Args = []string{"-seed", "42", "-workers", "4"}
+	meta.Started = time.Date(2025, 6, 14, 20, 43, 1, 0, time.UTC)
+	meta.Generated = meta.Started.Add(time.Minute)
+	
+	got := parseMetadataHeader(metadataHeader(meta, "run.meta.json"))
+	if !got.Started.Equal(meta.Started) || !got.Generated.Equal(meta.Generated) {
+		t.Errorf("times %v %v, want %v %v", got.Started, got.Generated, meta.Started, meta.Generated)
+	}
+	got.Started, got.Generated = meta.Started, meta.Generated
+	
+	tests := []struct {
+		field     string
+		got, want any
+	}{
+		{"Program", []string{got.Program, got.Version, got.GoVersion}, []string{meta.Program, meta.Version, meta.GoVersion}},
+		{"Args", got.Args, meta.Args},
+		{"Ticks", got.Ticks, meta.Ticks},
+		{"Samples", got.Samples, meta.Samples},
+		{"SampleInterval", got.SampleInterval, meta.SampleInterval},
+		{"Seed", got.Seed, meta.Seed},
+		{"Grid size", []int{got.GridWidth, got.GridHeight}, []int{meta.GridWidth, meta.GridHeight}},
+		{"Workers", []int{got.Workers, got.TileSize}, []int{meta.Workers, meta.TileSize}},
+		{"Features", got.Features, meta.Features},
+		{"Params", got.Params, meta.Params},
+		{"ParamLog", got.ParamLog, meta.ParamLog},
+		{"ChangeLog", got.ChangeLog, meta.ChangeLog},
+		{"RabbitParams", got.RabbitParams, roundedSpecies(meta.RabbitParams)},
+		{"FoxParams", got.FoxParams, roundedSpecies(meta.FoxParams)},
+		{"Regions", got.Regions, meta.Regions},
+		{"Constants", got.Constants, meta.Constants},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s:\n got %v\nwant %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+// roundedSpecies is what the header keeps of species parameters.
+func roundedSpecies(p SpeciesParams) SpeciesParams {
+	p.IdleCost, p.MoveCost, p.ChaseCost, p.DigestCost = rounded(p.IdleCost, 4), rounded(p.MoveCost, 4), rounded(p.ChaseCost, 4), rounded(p.DigestCost, 4)
+	p.ReproduceCost = rounded(p.ReproduceCost, 1)
+	return p
+}
+
+func rounded(v float64, digits int) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', digits, 64), 64)
+	return r
+}
+
+// The checked-in runs from before the metadata header was extended must still
+// load with the interval their samples were taken at.
+func TestParseOlderMetadataHeader(t *testing.T) {
+	run, err := loadReplayCSV("ecosystem_data_2025-06-14_20-43-01.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := run.meta
+	
+	tests := []struct {
+		field     string
+		got, want any
+	}{
+		{"Generated", meta.Generated, time.Date(2025, 6, 14, 20, 43, 1, 0, time.Local)},
+		{"Ticks", meta.Ticks, 870},
+		{"Samples", meta.Samples, 30},
+		{"SampleInterval", meta.SampleInterval, 30},
+		{"History interval", run.history.interval, 30},
+		{"Grid size", []int{meta.GridWidth, meta.GridHeight}, []int{80, 60}},
+		{"MaxRabbits", meta.Params.MaxRabbits, 50},
+		{"MaxFoxes", meta.Params.MaxFoxes, 15},
+		{"Regions", len(meta.Regions), 0},
+		{"Constants", len(meta.Constants), 0},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadReplayCSV(t *testing.T) {
+	t.Chdir(t.TempDir())
+	history, world := exportedRun()
+	// An interval other than the spacing of the samples shows that the
+	// loader takes it from the header
+	history.interval = 5
+	samples := historySamples(t, history)
+	formats, _ := parseFormats("csv")
+	exportPopulationData(history, world, formats)
+	
+	run, err := loadReplayCSV(exportedFile(t, "ecosystem_data_*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.history.interval != 5 {
+		t.Errorf("sample interval %d, want 5 from the header", run.history.interval)
+	}
+	if len(run.meta.Regions) != 2 || run.meta.Regions[0].Name != "Marsh" {
+		t.Errorf("regions %v", run.meta.Regions)
+	}
+	compareSamples(t, historySamples(t, run.history), samples)
+}
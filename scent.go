@@ -0,0 +1,77 @@
+package main
+
+import "math/rand"
+
+// Rabbits leave scent on every cell they occupy. The scent decays each tick,
+// so a fox that has no rabbit in sight can follow the gradient towards the
+// freshest part of a trail.
+
+func newScentField(width, height int) [][]float64 {
+	scent := make([][]float64, width)
+	for x := 0; x < width; x++ {
+		scent[x] = make([]float64, height)
+	}
+	return scent
+}
+
+func (w *World) updateScent() {
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			w.decayScentAt(x, y)
+		}
+	}
+}
+
+func (w *World) decayScentAt(x, y int) {
+	if w.Scent[x][y] == 0 {
+		return
+	}
+	
+	w.Scent[x][y] *= scentDecay
+	if w.Scent[x][y] < scentThreshold {
+		w.Scent[x][y] = 0
+	}
+}
+
+func (w *World) depositScent(pos Position) {
+	w.Scent[pos.X][pos.Y] += scentDeposit
+	if w.Scent[pos.X][pos.Y] > maxScent {
+		w.Scent[pos.X][pos.Y] = maxScent
+	}
+}
+
+// followScent picks the neighbouring cell with the strongest scent that is
+// stronger than the current one. ok is false when there is no trail to follow
+// or scent tracking is switched off.
+func (w *World) followScent(current Position, rng *rand.Rand) (Position, bool) {
+	if !w.scentTracking {
+		return current, false
+	}
+	
+	best := w.Scent[current.X][current.Y]
+	if best < scentThreshold {
+		best = scentThreshold
+	}
+	
+	candidates := make([]Position, 0)
+	for _, pos := range w.getAdjacentPositions(current) {
+		cellType := w.Grid[pos.X][pos.Y]
+		if cellType != Empty && cellType != GrassType && cellType != RabbitType {
+			continue
+		}
+		
+		strength := w.Scent[pos.X][pos.Y]
+		if strength > best {
+			best = strength
+			candidates = candidates[:0]
+			candidates = append(candidates, pos)
+		} else if strength == best && len(candidates) > 0 {
+			candidates = append(candidates, pos)
+		}
+	}
+	
+	if len(candidates) == 0 {
+		return current, false
+	}
+	return candidates[rng.Intn(len(candidates))], true
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Settings panel for tuning the simulation while it runs. Each parameter has
+// a row with -/+ steppers and a slider; changes apply to the live world at
+// once and are kept in its parameter log. A slider drag is logged as a single
+// change when the mouse button is released.
+
+func (g *Game) toggleSettings() {
+	g.showSettings = !g.showSettings
+	if !g.showSettings {
+		g.finishParamDrag()
+	}
+}
+
+func settingsPanelHeight() int {
+	return 22 + len(tunables)*settingsRowHeight
+}
+
+func insideSettingsPanel(x, y int) bool {
+	return x >= settingsPanelX && x < settingsPanelX+settingsPanelWidth &&
+		y >= settingsPanelY && y < settingsPanelY+settingsPanelHeight()
+}
+
+// settingsRow returns the left edges of the parts of a row.
+func settingsRow(i int) (y, minus, slider, plus int) {
+	y = settingsPanelY + 20 + i*settingsRowHeight
+	minus = settingsPanelX + 6 + settingsNameWidth + settingsValueWidth
+	slider = minus + settingsStepWidth + 4
+	plus = slider + settingsSliderWidth + 4
+	return y, minus, slider, plus
+}
+
+// handleSettingsMouse handles clicks on the settings panel. It returns true
+// when the mouse is busy with the panel, so clicks do not reach the world.
+func (g *Game) handleSettingsMouse() bool {
+	x, y := ebiten.CursorPosition()
+	
+	if g.paramDrag != nil {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			g.finishParamDrag()
+			return true
+		}
+		g.dragParam(x)
+		return true
+	}
+	
+	if !insideSettingsPanel(x, y) {
+		return false
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return true
+	}
+	
+	for i := range tunables {
+		t := &tunables[i]
+		rowY, minus, slider, plus := settingsRow(i)
+		if y < rowY || y >= rowY+settingsRowHeight-2 || t.overriddenBy(g.world) != "" {
+			continue
+		}
+		
+		switch {
+		case x >= minus && x < minus+settingsStepWidth:
+			g.world.setParam(t, t.value(&g.world.Params)-t.step)
+		case x >= plus && x < plus+settingsStepWidth:
+			g.world.setParam(t, t.value(&g.world.Params)+t.step)
+		case x >= slider && x < slider+settingsSliderWidth:
+			g.paramDrag = t
+			g.paramDragRow = i
+			g.paramDragOld = t.value(&g.world.Params)
+			g.dragParam(x)
+		}
+	}
+	return true
+}
+
+// dragParam sets the dragged parameter from the cursor position on its slider.
+func (g *Game) dragParam(x int) {
+	_, _, slider, _ := settingsRow(g.paramDragRow)
+	fraction := float64(x-slider) / float64(settingsSliderWidth-1)
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	
+	t := g.paramDrag
+	t.set(&g.world.Params, t.min+fraction*(t.max-t.min))
+}
+
+func (g *Game) finishParamDrag() {
+	if g.paramDrag == nil {
+		return
+	}
+	
+	g.world.logParamChange(g.paramDrag, g.paramDragOld)
+	g.paramDrag = nil
+}
+
+func (g *Game) drawSettingsPanel(screen *ebiten.Image) {
+	g.fillRect(screen, settingsPanelX, settingsPanelY, settingsPanelWidth, settingsPanelHeight(), color.RGBA{20, 20, 30, 230})
+	title := fmt.Sprintf("PARAMETERS (%d changes)", len(g.world.ParamLog))
+	ebitenutil.DebugPrintAt(screen, title, settingsPanelX+6, settingsPanelY+3)
+	
+	defaults := defaultSimParams()
+	for i := range tunables {
+		t := &tunables[i]
+		y, minus, slider, plus := settingsRow(i)
+		value := t.value(&g.world.Params)
+		
+		// Values changed from their defaults are marked in yellow
+		valueColor := color.RGBA{200, 200, 200, 255}
+		if value != t.value(&defaults) {
+			valueColor = color.RGBA{255, 200, 0, 255}
+			g.fillRect(screen, minus-settingsValueWidth, y+6, 3, 3, valueColor)
+		}
+		ebitenutil.DebugPrintAt(screen, t.name, settingsPanelX+6, y)
+		ebitenutil.DebugPrintAt(screen, t.format(value), minus-settingsValueWidth+5, y)
+		
+		// Rows replaced by an enabled feature have no controls
+		if by := t.overriddenBy(g.world); by != "" {
+			ebitenutil.DebugPrintAt(screen, "set by "+by, minus+4, y)
+			continue
+		}
+		
+		g.fillRect(screen, minus, y, settingsStepWidth, settingsRowHeight-2, color.RGBA{60, 60, 70, 255})
+		ebitenutil.DebugPrintAt(screen, "-", minus+4, y)
+		g.fillRect(screen, plus, y, settingsStepWidth, settingsRowHeight-2, color.RGBA{60, 60, 70, 255})
+		ebitenutil.DebugPrintAt(screen, "+", plus+4, y)
+		
+		g.fillRect(screen, slider, y+6, settingsSliderWidth, 4, color.RGBA{50, 50, 60, 255})
+		filled := 0
+		if t.max > t.min {
+			filled = int((value - t.min) / (t.max - t.min) * float64(settingsSliderWidth-1))
+		}
+		if filled > 0 {
+			g.fillRect(screen, slider, y+6, filled, 4, color.RGBA{90, 90, 140, 255})
+		}
+		g.fillRect(screen, slider+filled-1, y+2, 3, settingsRowHeight-6, valueColor)
+	}
+}
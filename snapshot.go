@@ -0,0 +1,200 @@
+package main
+
+// Snapshots of the world for rewinding. Only occupied grid cells and cells
+// with scent are stored, and consecutive snapshots share one copy of the
+// region map until it changes, so a snapshot costs roughly as much as the
+// entities in it.
+
+type Snapshot struct {
+	Tick int
+	
+	cells   []snapshotCell
+	scent   []snapshotScent
+	grass   []Grass
+	rabbits []Rabbit
+	foxes   []Fox
+	
+	regionMap     [][]int
+	regionVersion int
+	
+	huntAttempts  int
+	huntSuccesses int
+	events        PopulationEvents
+	nextID        int
+	rabbitParams  SpeciesParams
+	foxParams     SpeciesParams
+}
+
+type snapshotCell struct {
+	index int32
+	kind  EntityType
+}
+
+type snapshotScent struct {
+	index    int32
+	strength float32
+}
+
+// snapshot copies the world. prev is the last snapshot taken, if any, and is
+// used to share the region map.
+func (w *World) snapshot(prev *Snapshot) *Snapshot {
+	s := &Snapshot{
+		Tick:          w.Tick,
+		grass:         make([]Grass, 0, len(w.Grass)),
+		rabbits:       make([]Rabbit, len(w.Rabbits)),
+		foxes:         make([]Fox, len(w.Foxes)),
+		regionVersion: w.RegionVersion,
+		huntAttempts:  w.HuntAttempts,
+		huntSuccesses: w.HuntSuccesses,
+		events:        w.Events,
+		nextID:        w.nextID,
+		rabbitParams:  w.RabbitParams,
+		foxParams:     w.FoxParams,
+	}
+	
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			index := int32(x*w.Height + y)
+			if w.Grid[x][y] != Empty {
+				s.cells = append(s.cells, snapshotCell{index, w.Grid[x][y]})
+			}
+			if w.Scent[x][y] != 0 {
+				s.scent = append(s.scent, snapshotScent{index, float32(w.Scent[x][y])})
+			}
+		}
+	}
+	
+	for _, grass := range w.Grass {
+		s.grass = append(s.grass, *grass)
+	}
+	for i, rabbit := range w.Rabbits {
+		s.rabbits[i] = *rabbit
+	}
+	for i, fox := range w.Foxes {
+		s.foxes[i] = *fox
+		if fox.PackTarget != nil {
+			target := *fox.PackTarget
+			s.foxes[i].PackTarget = &target
+		}
+	}
+	
+	if prev != nil && prev.regionVersion == w.RegionVersion {
+		s.regionMap = prev.regionMap
+	} else {
+		s.regionMap = make([][]int, w.Width)
+		for x := range w.RegionMap {
+			s.regionMap[x] = append([]int(nil), w.RegionMap[x]...)
+		}
+	}
+	
+	return s
+}
+
+// restore puts the world back into the state of a snapshot. Feature toggles
+// and tuned parameters are left as they are.
+func (w *World) restore(s *Snapshot) {
+	w.Tick = s.Tick
+	w.HuntAttempts = s.huntAttempts
+	w.HuntSuccesses = s.huntSuccesses
+	w.Events = s.events
+	w.nextID = s.nextID
+	w.RabbitParams = s.rabbitParams
+	w.FoxParams = s.foxParams
+	
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			w.Grid[x][y] = Empty
+			w.Scent[x][y] = 0
+		}
+	}
+	for _, cell := range s.cells {
+		w.Grid[int(cell.index)/w.Height][int(cell.index)%w.Height] = cell.kind
+	}
+	for _, scent := range s.scent {
+		w.Scent[int(scent.index)/w.Height][int(scent.index)%w.Height] = float64(scent.strength)
+	}
+	
+	w.Grass = make(map[Position]*Grass, len(s.grass))
+	for i := range s.grass {
+		grass := s.grass[i]
+		w.Grass[grass.Position] = &grass
+	}
+	
+	w.Rabbits = make([]*Rabbit, len(s.rabbits))
+	for i := range s.rabbits {
+		rabbit := s.rabbits[i]
+		w.Rabbits[i] = &rabbit
+	}
+	
+	w.Foxes = make([]*Fox, len(s.foxes))
+	w.Packs = w.Packs[:0]
+	for i := range s.foxes {
+		fox := s.foxes[i]
+		if fox.PackTarget != nil {
+			target := *fox.PackTarget
+			fox.PackTarget = &target
+		}
+		w.Foxes[i] = &fox
+		
+		for len(w.Packs) < fox.PackID {
+			w.Packs = append(w.Packs, nil)
+		}
+		if fox.PackID > 0 {
+			w.Packs[fox.PackID-1] = append(w.Packs[fox.PackID-1], &fox)
+		}
+	}
+	
+	if s.regionVersion != w.RegionVersion {
+		for x := range w.RegionMap {
+			copy(w.RegionMap[x], s.regionMap[x])
+		}
+		w.updateRegionDistances()
+	}
+	
+	w.regionCounts = w.countByRegion()
+}
+
+// snapshotRing keeps the most recent snapshots, dropping the oldest when full.
+type snapshotRing struct {
+	items []*Snapshot
+	start int
+	count int
+}
+
+func newSnapshotRing(capacity int) *snapshotRing {
+	return &snapshotRing{items: make([]*Snapshot, capacity)}
+}
+
+func (r *snapshotRing) len() int {
+	return r.count
+}
+
+// at returns the i-th snapshot, oldest first.
+func (r *snapshotRing) at(i int) *Snapshot {
+	return r.items[(r.start+i)%len(r.items)]
+}
+
+func (r *snapshotRing) last() *Snapshot {
+	if r.count == 0 {
+		return nil
+	}
+	return r.at(r.count - 1)
+}
+
+// add appends a snapshot. Snapshots from the same tick or later are dropped
+// first, so resuming from a past tick discards the old future.
+func (r *snapshotRing) add(s *Snapshot) {
+	for r.count > 0 && r.last().Tick >= s.Tick {
+		r.count--
+		r.items[(r.start+r.count)%len(r.items)] = nil
+	}
+	
+	if r.count == len(r.items) {
+		r.items[r.start] = nil
+		r.start = (r.start + 1) % len(r.items)
+		r.count--
+	}
+	
+	r.items[(r.start+r.count)%len(r.items)] = s
+	r.count++
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// runningWorld is a seeded world with every feature that keeps state between
+// ticks switched on.
+func runningWorld(ticks int) *World {
+	rand.Seed(11)
+	w := NewWorld(60, 40)
+	w.Seed = 11
+	w.packHunting = true
+	w.herding = true
+	w.regionsEnabled = true
+	w.migration = true
+	w.addTestEntities()
+	for i := 0; i < ticks; i++ {
+		w.Update()
+		w.Tick++
+	}
+	return w
+}
+
+// snapshotState is the fingerprint plus the counters a snapshot keeps. Scent
+// is stored in single precision, so the live world's scent is rounded first.
+func snapshotState(w *World) string {
+	for x := range w.Scent {
+		for y := range w.Scent[x] {
+			w.Scent[x][y] = float64(float32(w.Scent[x][y]))
+		}
+	}
+	return fmt.Sprintf("%s\nnext ID %d events %+v regions %v", fingerprint(w), w.nextID, w.Events, w.RegionMap)
+}
+
+func TestRestoreReturnsToSnapshot(t *testing.T) {
+	w := runningWorld(400)
+	want := snapshotState(w)
+	s := w.snapshot(nil)
+	
+	for i := 0; i < 200; i++ {
+		w.Update()
+		w.Tick++
+	}
+	w.restore(s)
+	if got := snapshotState(w); got != want {
+		t.Fatal("restored world differs from the snapshot")
+	}
+	checkPacks(t, w)
+	
+	// Running on from the restored world must not change the snapshot, and a
+	// seeded continuation is the same every time
+	var continuations [2]string
+	for i := range continuations {
+		w.restore(s)
+		rand.Seed(3)
+		for tick := 0; tick < 100; tick++ {
+			w.Update()
+			w.Tick++
+		}
+		continuations[i] = fingerprint(w)
+	}
+	if continuations[0] != continuations[1] {
+		t.Error("runs from the same snapshot differ")
+	}
+	w.restore(s)
+	if snapshotState(w) != want {
+		t.Error("running after a restore changed the snapshot")
+	}
+}
+
+// checkPacks verifies that packs hold exactly the foxes that name them.
+func checkPacks(t *testing.T, w *World) {
+	t.Helper()
+	members := 0
+	for i, pack := range w.Packs {
+		for _, fox := range pack {
+			members++
+			if fox.PackID != i+1 {
+				t.Errorf("fox %d in pack %d has pack ID %d", fox.Animal.ID, i+1, fox.PackID)
+			}
+			if w.foxIndex(fox.Animal.ID) < 0 || w.Foxes[w.foxIndex(fox.Animal.ID)] != fox {
+				t.Errorf("pack %d holds fox %d that is not in the world", i+1, fox.Animal.ID)
+			}
+		}
+	}
+	for _, fox := range w.Foxes {
+		if fox.PackID > 0 {
+			members--
+		}
+	}
+	if members != 0 {
+		t.Errorf("pack membership and fox pack IDs disagree by %d", members)
+	}
+}
+
+func TestSnapshotRing(t *testing.T) {
+	tests := []struct {
+		name  string
+		ticks []int // Ticks of the snapshots added, in order
+		want  []int // Ticks kept, oldest first
+	}{
+		{"empty", nil, nil},
+		{"below capacity", []int{0, 10}, []int{0, 10}},
+		{"full", []int{0, 10, 20}, []int{0, 10, 20}},
+		{"wraps around", []int{0, 10, 20, 30, 40}, []int{20, 30, 40}},
+		{"rewind drops the future", []int{0, 10, 20, 10}, []int{0, 10}},
+		{"rewind after wrapping", []int{0, 10, 20, 30, 40, 30, 40, 50}, []int{30, 40, 50}},
+	}
+	
+	for _, tt := range tests {
+		r := newSnapshotRing(3)
+		for _, tick := range tt.ticks {
+			r.add(&Snapshot{Tick: tick})
+		}
+		var got []int
+		for i := 0; i < r.len(); i++ {
+			got = append(got, r.at(i).Tick)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: kept %v, want %v", tt.name, got, tt.want)
+		}
+		if r.len() > 0 && r.last().Tick != tt.want[len(tt.want)-1] {
+			t.Errorf("%s: last %d", tt.name, r.last().Tick)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Simulation speed. Slow levels run one tick every few frames, fast levels
+// several ticks per frame. Max speed and fast-forward run as many ticks as
+// fit in maxSpeedFrameMillis each frame so the window stays responsive.
+
+type speedLevel struct {
+	frames int // Frames between updates
+	ticks  int // Ticks run per update
+	name   string
+}
+
+var speedLevels = []speedLevel{
+	{60, 1, "0.1x"},
+	{30, 1, "0.3x"},
+	{20, 1, "0.5x"},
+	{10, 1, "1x"},
+	{5, 1, "2x"},
+	{2, 1, "5x"},
+	{1, 1, "10x"},
+	{1, 4, "40x"},
+	{1, 16, "160x"},
+}
+
+func (g *Game) advance() {
+	if g.targetTick > 0 {
+		g.runFor(func() bool { return g.world.Tick < g.targetTick })
+		if g.world.Tick >= g.targetTick {
+			log.Printf("Reached tick %d", g.world.Tick)
+			g.targetTick = 0
+			g.paused = true
+		}
+		return
+	}
+	
+	if g.maxSpeed {
+		g.runFor(func() bool { return true })
+		return
+	}
+	
+	level := speedLevels[g.speedLevel]
+	g.tickCounter++
+	if g.tickCounter >= level.frames {
+		g.tickCounter = 0
+		for i := 0; i < level.ticks; i++ {
+			g.step()
+		}
+	}
+}
+
+// runFor steps the simulation while more is true and the frame budget lasts.
+func (g *Game) runFor(more func() bool) {
+	start := time.Now()
+	g.ticksLastFrame = 0
+	for more() && time.Since(start) < maxSpeedFrameMillis*time.Millisecond {
+		g.step()
+		g.ticksLastFrame++
+	}
+}
+
+func (g *Game) changeSpeed(delta int) {
+	g.maxSpeed = false
+	g.speedLevel += delta
+	if g.speedLevel < 0 {
+		g.speedLevel = 0
+	}
+	if g.speedLevel >= len(speedLevels) {
+		g.speedLevel = len(speedLevels) - 1
+	}
+	g.tickCounter = 0
+	log.Printf("Speed: %s", speedLevels[g.speedLevel].name)
+}
+
+func (g *Game) toggleMaxSpeed() {
+	g.maxSpeed = !g.maxSpeed
+	if g.maxSpeed {
+		log.Println("Speed: MAX")
+	} else {
+		log.Printf("Speed: %s", speedLevels[g.speedLevel].name)
+	}
+}
+
+// stepOnce advances a paused simulation by a single tick.
+func (g *Game) stepOnce() {
+	if !g.paused {
+		return
+	}
+	
+	g.step()
+	log.Printf("Stepped to tick %d", g.world.Tick)
+}
+
+// handleTickInput reads the target tick typed after pressing G. It returns
+// true while the prompt is open so other keys are not handled.
+func (g *Game) handleTickInput() bool {
+	if !g.enteringTick {
+		return false
+	}
+	
+	for _, char := range ebiten.AppendInputChars(nil) {
+		if char >= '0' && char <= '9' && len(g.tickInput) < 9 {
+			g.tickInput += string(char)
+		}
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.tickInput) > 0 {
+		g.tickInput = g.tickInput[:len(g.tickInput)-1]
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.enteringTick = false
+		g.tickInput = ""
+	}
+	
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyKPEnter) {
+		g.enteringTick = false
+		target, err := strconv.Atoi(g.tickInput)
+		g.tickInput = ""
+		if err != nil || target <= g.world.Tick {
+			log.Printf("Go to tick: need a tick after %d", g.world.Tick)
+			return true
+		}
+		
+		g.targetTick = target
+		g.paused = false
+		log.Printf("Fast-forwarding to tick %d", target)
+	}
+	
+	return true
+}
+
+func (g *Game) speedStatus() string {
+	switch {
+	case g.enteringTick:
+		return fmt.Sprintf("Go to tick: %s_ (ENTER=Go ESC=Cancel)", g.tickInput)
+	case g.targetTick > 0:
+		return fmt.Sprintf("Speed: FAST-FORWARD to %d (%d ticks/frame)", g.targetTick, g.ticksLastFrame)
+	case g.maxSpeed:
+		return fmt.Sprintf("Speed: MAX (%d ticks/frame)", g.ticksLastFrame)
+	default:
+		return fmt.Sprintf("Speed: %s", speedLevels[g.speedLevel].name)
+	}
+}
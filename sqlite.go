@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+// SQLite export. The database is written directly in the SQLite file format
+// (https://www.sqlite.org/fileformat.html), so no database library or cgo is
+// needed: every table is a table b-tree of leaf pages, with interior pages
+// above them when it needs more than one, and long rows continue on overflow
+// pages. The file is written once and never updated, so there are no free
+// pages, indexes or journals.
+
+const (
+	sqlitePageSize     = 4096
+	sqliteHeaderSize   = 100
+	sqliteLeafHeader   = 8
+	sqliteInnerHeader  = 12
+	sqliteLeafPage     = 0x0d
+	sqliteInteriorPage = 0x05
+	sqliteVersion      = 3040001 // Library version recorded in the header
+)
+
+type sqliteWriter struct {
+	file  *os.File
+	pages uint32 // Pages allocated so far, including page 1
+}
+
+func (w *sqliteWriter) newPage() uint32 {
+	w.pages++
+	return w.pages
+}
+
+func (w *sqliteWriter) writePage(number uint32, page []byte) error {
+	_, err := w.file.WriteAt(page, int64(number-1)*sqlitePageSize)
+	return err
+}
+
+// sqliteVarint appends v in SQLite's big-endian variable-length encoding.
+func sqliteVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var tmp [9]byte
+		tmp[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			tmp[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, tmp[:]...)
+	}
+	
+	var tmp [8]byte
+	n := 0
+	for {
+		tmp[n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		b := tmp[i]
+		if i > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+	}
+	return buf
+}
+
+func sqliteVarintLen(v uint64) int {
+	return len(sqliteVarint(nil, v))
+}
+
+// sqliteRecord encodes a row in the record format. Values may be nil, int,
+// int64, float64 or string.
+func sqliteRecord(values []any) []byte {
+	var header, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = sqliteVarint(header, 0)
+		case int:
+			header, body = sqliteInteger(header, body, int64(v))
+		case int64:
+			header, body = sqliteInteger(header, body, v)
+		case float64:
+			header = sqliteVarint(header, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			header = sqliteVarint(header, uint64(13+2*len(v)))
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqlite: unsupported value %T", value))
+		}
+	}
+	
+	// The header size includes its own varint
+	size := 1
+	for sqliteVarintLen(uint64(len(header)+size)) != size {
+		size++
+	}
+	record := sqliteVarint(nil, uint64(len(header)+size))
+	record = append(record, header...)
+	return append(record, body...)
+}
+
+// sqliteInteger stores v in the smallest integer serial type that holds it.
+func sqliteInteger(header, body []byte, v int64) ([]byte, []byte) {
+	switch {
+	case v == 0:
+		return sqliteVarint(header, 8), body
+	case v == 1:
+		return sqliteVarint(header, 9), body
+	}
+	
+	sizes := []struct {
+		serialType uint64
+		bytes      int
+	}{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 6}, {6, 8}}
+	for _, size := range sizes {
+		bits := uint(size.bytes * 8)
+		if size.bytes == 8 || (v >= -1<<(bits-1) && v < 1<<(bits-1)) {
+			for i := size.bytes - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(uint(i)*8)))
+			}
+			return sqliteVarint(header, size.serialType), body
+		}
+	}
+	return header, body
+}
+
+// leafCell builds a table leaf cell. Payload that does not fit in the page is
+// written to a chain of overflow pages.
+func (w *sqliteWriter) leafCell(rowid int64, payload []byte) ([]byte, error) {
+	cell := sqliteVarint(nil, uint64(len(payload)))
+	cell = sqliteVarint(cell, uint64(rowid))
+	
+	usable := sqlitePageSize
+	maxLocal := usable - 35
+	if len(payload) <= maxLocal {
+		return append(cell, payload...), nil
+	}
+	
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+	
+	rest := payload[local:]
+	next := w.newPage()
+	cell = binary.BigEndian.AppendUint32(cell, next)
+	for len(rest) > 0 {
+		page := make([]byte, sqlitePageSize)
+		number := next
+		chunk := min(len(rest), usable-4)
+		copy(page[4:], rest[:chunk])
+		rest = rest[chunk:]
+		if len(rest) > 0 {
+			next = w.newPage()
+			binary.BigEndian.PutUint32(page, next)
+		}
+		if err := w.writePage(number, page); err != nil {
+			return nil, err
+		}
+	}
+	return cell, nil
+}
+
+// fillPage lays out a b-tree page whose header starts at offset: the cell
+// pointer array after the header and the cells packed at the end of the page.
+func fillPage(page []byte, offset int, pageType byte, cells [][]byte, rightChild uint32) {
+	headerSize := sqliteLeafHeader
+	if pageType == sqliteInteriorPage {
+		headerSize = sqliteInnerHeader
+		binary.BigEndian.PutUint32(page[offset+8:], rightChild)
+	}
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	
+	content := len(page)
+	pointer := offset + headerSize
+	for _, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointer:], uint16(content))
+		pointer += 2
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+}
+
+// table writes a table b-tree with the rows produced by rows, numbered from
+// 1, and returns its root page.
+func (w *sqliteWriter) table(rows func(add func(values ...any) error) error) (uint32, error) {
+	type child struct {
+		page   uint32
+		maxKey int64
+	}
+	var level []child
+	var cells [][]byte
+	used := sqliteLeafHeader
+	rowid := int64(0)
+	
+	flushLeaf := func() error {
+		page := make([]byte, sqlitePageSize)
+		fillPage(page, 0, sqliteLeafPage, cells, 0)
+		number := w.newPage()
+		level = append(level, child{number, rowid})
+		cells, used = nil, sqliteLeafHeader
+		return w.writePage(number, page)
+	}
+	
+	err := rows(func(values ...any) error {
+		cell, err := w.leafCell(rowid+1, sqliteRecord(values))
+		if err != nil {
+			return err
+		}
+		if used+len(cell)+2 > sqlitePageSize {
+			if err := flushLeaf(); err != nil {
+				return err
+			}
+		}
+		rowid++
+		cells = append(cells, cell)
+		used += len(cell) + 2
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := flushLeaf(); err != nil {
+		return 0, err
+	}
+	
+	// Interior pages: a cell with the child page and its largest rowid for
+	// every child except the last, which is the right-most pointer
+	for len(level) > 1 {
+		var next []child
+		var group []child
+		used := sqliteInnerHeader
+		flushInterior := func() error {
+			last := group[len(group)-1]
+			var cells [][]byte
+			for _, c := range group[:len(group)-1] {
+				cell := binary.BigEndian.AppendUint32(nil, c.page)
+				cells = append(cells, sqliteVarint(cell, uint64(c.maxKey)))
+			}
+			page := make([]byte, sqlitePageSize)
+			fillPage(page, 0, sqliteInteriorPage, cells, last.page)
+			number := w.newPage()
+			next = append(next, child{number, last.maxKey})
+			group, used = nil, sqliteInnerHeader
+			return w.writePage(number, page)
+		}
+		
+		for _, c := range level {
+			size := 4 + sqliteVarintLen(uint64(c.maxKey)) + 2
+			if used+size > sqlitePageSize && len(group) > 1 {
+				if err := flushInterior(); err != nil {
+					return 0, err
+				}
+			}
+			group = append(group, c)
+			used += size
+		}
+		if err := flushInterior(); err != nil {
+			return 0, err
+		}
+		level = next
+	}
+	return level[0].page, nil
+}
+
+// finish writes page 1: the database header and the schema table, which must
+// fit in that one page.
+func (w *sqliteWriter) finish(schema [][]any) error {
+	var cells [][]byte
+	used := sqliteHeaderSize + sqliteLeafHeader
+	for i, row := range schema {
+		cell, err := w.leafCell(int64(i+1), sqliteRecord(row))
+		if err != nil {
+			return err
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+	if used > sqlitePageSize {
+		return fmt.Errorf("sqlite schema does not fit in the first page")
+	}
+	
+	page := make([]byte, sqlitePageSize)
+	copy(page, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(page[16:], sqlitePageSize)
+	page[18], page[19] = 1, 1 // Legacy (rollback journal) read and write version
+	page[21], page[22], page[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(page[24:], 1) // File change counter
+	binary.BigEndian.PutUint32(page[28:], w.pages)
+	binary.BigEndian.PutUint32(page[40:], 1) // Schema cookie
+	binary.BigEndian.PutUint32(page[44:], 4) // Schema format
+	binary.BigEndian.PutUint32(page[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(page[92:], 1) // Version-valid-for, matches the change counter
+	binary.BigEndian.PutUint32(page[96:], sqliteVersion)
+	fillPage(page, sqliteHeaderSize, sqliteLeafPage, cells, 0)
+	return w.writePage(1, page)
+}
+
+func sqliteCreateTable(name string, columns []exportColumn) string {
+	defs := make([]string, len(columns))
+	for i, column := range columns {
+		kind := "INTEGER"
+		switch column.kind {
+		case floatColumn:
+			kind = "REAL"
+		case textColumn:
+			kind = "TEXT"
+		}
+		defs[i] = fmt.Sprintf("\"%s\" %s", strings.ReplaceAll(column.name, "\"", "\"\""), kind)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", name, strings.Join(defs, ", "))
+}
+
+// writeSQLite writes a database with three tables: samples (the same columns
+// as the CSV file), events (the event log) and metadata (one row per metadata
+// field, nested values as JSON).
+func writeSQLite(export *exportRun) error {
+	metaJSON, err := json.Marshal(export.meta)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(metaJSON, &fields); err != nil {
+		return err
+	}
+	
+	filename := export.dataFilename("sqlite")
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := &sqliteWriter{file: file}
+	w.newPage() // Page 1 is written last, once the root pages are known
+	
+	samplesRoot, err := w.table(func(add func(values ...any) error) error {
+		values := make([]any, len(export.columns))
+		return export.history.each(func(data PopulationData) error {
+			for i, column := range export.columns {
+				values[i] = column.value(data)
+			}
+			return add(values...)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	
+	eventsRoot, err := w.table(func(add func(values ...any) error) error {
+		for _, event := range export.events {
+			var detail any
+			if event.Detail != "" {
+				detail = event.Detail
+			}
+			if err := add(event.Tick, event.Event, event.Count, detail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	metadataRoot, err := w.table(func(add func(values ...any) error) error {
+		for _, key := range sortedKeys(fields) {
+			value := string(fields[key])
+			var text string
+			if json.Unmarshal(fields[key], &text) == nil {
+				value = text
+			}
+			if err := add(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	tables := []struct {
+		name string
+		root uint32
+		sql  string
+	}{
+		{"samples", samplesRoot, sqliteCreateTable("samples", export.columns)},
+		{"events", eventsRoot, sqliteCreateTable("events", eventColumns)},
+		{"metadata", metadataRoot, "CREATE TABLE metadata (\"Key\" TEXT, \"Value\" TEXT)"},
+	}
+	var schema [][]any
+	for _, table := range tables {
+		schema = append(schema, []any{"table", table.name, table.name, int64(table.root), table.sql})
+	}
+	if err := w.finish(schema); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	
+	log.Printf("SQLite database exported to: %s", filename)
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+)
+
+// readSQLiteTables reads every table of a database written by writeSQLite,
+// following the file format independently of the writer.
+func readSQLiteTables(t *testing.T, db []byte) map[string][][]any {
+	t.Helper()
+	if string(db[:16]) != "SQLite format 3\x00" {
+		t.Fatalf("bad header %q", db[:16])
+	}
+	if size := int(binary.BigEndian.Uint16(db[16:])); size != sqlitePageSize || len(db)%size != 0 {
+		t.Fatalf("page size %d, file size %d", size, len(db))
+	}
+	if pages := binary.BigEndian.Uint32(db[28:]); int(pages)*sqlitePageSize != len(db) {
+		t.Fatalf("header counts %d pages, file has %d", pages, len(db)/sqlitePageSize)
+	}
+	
+	tables := map[string][][]any{}
+	for _, row := range sqliteTreeRows(t, db, 1) {
+		tables[row[1].(string)] = sqliteTreeRows(t, db, uint32(row[3].(int64)))
+	}
+	return tables
+}
+
+// sqliteTreeRows returns the records of a table b-tree in rowid order and
+// checks that the rowids count up from 1.
+func sqliteTreeRows(t *testing.T, db []byte, root uint32) [][]any {
+	t.Helper()
+	page := db[(root-1)*sqlitePageSize : root*sqlitePageSize]
+	offset := 0
+	if root == 1 {
+		offset = sqliteHeaderSize
+	}
+	count := int(binary.BigEndian.Uint16(page[offset+3:]))
+	
+	var rows [][]any
+	switch page[offset] {
+	case sqliteInteriorPage:
+		for i := 0; i < count; i++ {
+			cell := page[binary.BigEndian.Uint16(page[offset+sqliteInnerHeader+2*i:]):]
+			rows = append(rows, sqliteTreeRows(t, db, binary.BigEndian.Uint32(cell))...)
+		}
+		rows = append(rows, sqliteTreeRows(t, db, binary.BigEndian.Uint32(page[offset+8:]))...)
+	case sqliteLeafPage:
+		for i := 0; i < count; i++ {
+			cell := page[binary.BigEndian.Uint16(page[offset+sqliteLeafHeader+2*i:]):]
+			size, n := readSQLiteVarint(cell)
+			_, m := readSQLiteVarint(cell[n:])
+			rows = append(rows, decodeSQLiteRecord(t, sqlitePayload(db, cell[n+m:], int(size))))
+		}
+	default:
+		t.Fatalf("page %d has type %#x", root, page[offset])
+	}
+	return rows
+}
+
+// sqlitePayload collects a cell's payload, reading the overflow pages when it
+// does not fit in the page.
+func sqlitePayload(db, cell []byte, size int) []byte {
+	usable := sqlitePageSize
+	maxLocal := usable - 35
+	if size <= maxLocal {
+		return cell[:size]
+	}
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	
+	payload := append([]byte(nil), cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for len(payload) < size {
+		page := db[(next-1)*sqlitePageSize : next*sqlitePageSize]
+		chunk := min(size-len(payload), usable-4)
+		payload = append(payload, page[4:4+chunk]...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	return payload
+}
+
+func readSQLiteVarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(buf[i]&0x7f)
+		if buf[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(buf[8]), 9
+}
+
+func decodeSQLiteRecord(t *testing.T, record []byte) []any {
+	t.Helper()
+	headerSize, n := readSQLiteVarint(record)
+	header, body := record[n:headerSize], record[headerSize:]
+	
+	var values []any
+	for len(header) > 0 {
+		serialType, n := readSQLiteVarint(header)
+		header = header[n:]
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType <= 6:
+			size := []int{0, 1, 2, 3, 4, 6, 8}[serialType]
+			v := int64(int8(body[0]))
+			for _, b := range body[1:size] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+			body = body[size:]
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case serialType == 8 || serialType == 9:
+			values = append(values, int64(serialType-8))
+		case serialType >= 13 && serialType%2 == 1:
+			size := int(serialType-13) / 2
+			values = append(values, string(body[:size]))
+			body = body[size:]
+		default:
+			t.Fatalf("unexpected serial type %d", serialType)
+		}
+	}
+	return values
+}
+
+func TestSQLiteVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 240, 16383, 16384, 1 << 32, 1<<56 - 1, 1 << 56, math.MaxUint64} {
+		buf := sqliteVarint(nil, v)
+		got, n := readSQLiteVarint(buf)
+		if got != v || n != len(buf) || n != sqliteVarintLen(v) {
+			t.Errorf("%d: read back %d from %d of %d bytes", v, got, n, len(buf))
+		}
+	}
+}
+
+func TestSQLiteRecord(t *testing.T) {
+	tests := [][]any{
+		{nil, "", "text"},
+		{int64(0), int64(1), int64(-1), int64(127), int64(-129), int64(40000), int64(-1 << 23), int64(1 << 40), int64(math.MinInt64)},
+		{1.5, -0.0, math.MaxFloat64},
+	}
+	for _, values := range tests {
+		got := decodeSQLiteRecord(t, sqliteRecord(values))
+		for i := range values {
+			if got[i] != values[i] {
+				t.Errorf("value %d: %v, want %v", i, got[i], values[i])
+			}
+		}
+	}
+}
+
+// TestSQLiteLargeTable needs interior pages and overflow pages.
+func TestSQLiteLargeTable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	history := newPopulationHistory(1)
+	for tick := 0; tick < 3000; tick++ {
+		history.add(PopulationData{Tick: tick, Rabbits: tick % 97})
+	}
+	long := string(make([]byte, 3*sqlitePageSize))
+	export := &exportRun{
+		history:   history,
+		meta:      RunMetadata{Program: "ecosystem-sim", Args: []string{long}},
+		timestamp: "large",
+		columns:   sampleColumns(nil),
+		events:    []EventRecord{{Tick: 5, Event: "Edit", Count: 1, Detail: long}},
+	}
+	if err := writeSQLite(export); err != nil {
+		t.Fatal(err)
+	}
+	
+	data, err := os.ReadFile(export.dataFilename("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := readSQLiteTables(t, data)
+	if rows := tables["samples"]; len(rows) != 3000 || rows[2999][0] != int64(2999) || rows[2999][1] != int64(2999%97) {
+		t.Errorf("samples: %d rows", len(rows))
+	}
+	if rows := tables["events"]; len(rows) != 1 || rows[0][3] != long {
+		t.Errorf("long event detail did not survive the overflow pages")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+// Statistics recorded with every population sample: energy and age of each
+// species, grass biomass, and births and deaths by cause.
+
+// SpeciesStats summarises the energy and age of one species.
+type SpeciesStats struct {
+	MeanEnergy float64
+	MinEnergy  int
+	MaxEnergy  int
+	MeanAge    float64
+	MinAge     int
+	MaxAge     int
+}
+
+// PopulationEvents counts births and deaths by cause.
+type PopulationEvents struct {
+	RabbitBirths   int
+	FoxBirths      int
+	RabbitsEaten   int
+	RabbitsStarved int // Ran out of energy (hunger, crowding, failed escapes)
+	FoxesStarved   int
+}
+
+func speciesStats(animals []*Animal) SpeciesStats {
+	if len(animals) == 0 {
+		return SpeciesStats{}
+	}
+	
+	stats := SpeciesStats{
+		MinEnergy: animals[0].Energy,
+		MaxEnergy: animals[0].Energy,
+		MinAge:    animals[0].Age,
+		MaxAge:    animals[0].Age,
+	}
+	totalEnergy, totalAge := 0, 0
+	for _, animal := range animals {
+		totalEnergy += animal.Energy
+		totalAge += animal.Age
+		if animal.Energy < stats.MinEnergy {
+			stats.MinEnergy = animal.Energy
+		}
+		if animal.Energy > stats.MaxEnergy {
+			stats.MaxEnergy = animal.Energy
+		}
+		if animal.Age < stats.MinAge {
+			stats.MinAge = animal.Age
+		}
+		if animal.Age > stats.MaxAge {
+			stats.MaxAge = animal.Age
+		}
+	}
+	stats.MeanEnergy = float64(totalEnergy) / float64(len(animals))
+	stats.MeanAge = float64(totalAge) / float64(len(animals))
+	return stats
+}
+
+func (w *World) rabbitStats() SpeciesStats {
+	animals := make([]*Animal, len(w.Rabbits))
+	for i, rabbit := range w.Rabbits {
+		animals[i] = &rabbit.Animal
+	}
+	return speciesStats(animals)
+}
+
+func (w *World) foxStats() SpeciesStats {
+	animals := make([]*Animal, len(w.Foxes))
+	for i, fox := range w.Foxes {
+		animals[i] = &fox.Animal
+	}
+	return speciesStats(animals)
+}
+
+// grassBiomass returns the total amount of grass on the map.
+func (w *World) grassBiomass() int {
+	total := 0
+	for _, grass := range w.Grass {
+		total += grass.Amount
+	}
+	return total
+}
+
+// since returns the events that happened between prev and e.
+func (e PopulationEvents) since(prev PopulationEvents) PopulationEvents {
+	return PopulationEvents{
+		RabbitBirths:   e.RabbitBirths - prev.RabbitBirths,
+		FoxBirths:      e.FoxBirths - prev.FoxBirths,
+		RabbitsEaten:   e.RabbitsEaten - prev.RabbitsEaten,
+		RabbitsStarved: e.RabbitsStarved - prev.RabbitsStarved,
+		FoxesStarved:   e.FoxesStarved - prev.FoxesStarved,
+	}
+}
+
+func averageStats(a, b SpeciesStats) SpeciesStats {
+	return SpeciesStats{
+		MeanEnergy: (a.MeanEnergy + b.MeanEnergy) / 2,
+		MinEnergy:  (a.MinEnergy + b.MinEnergy) / 2,
+		MaxEnergy:  (a.MaxEnergy + b.MaxEnergy) / 2,
+		MeanAge:    (a.MeanAge + b.MeanAge) / 2,
+		MinAge:     (a.MinAge + b.MinAge) / 2,
+		MaxAge:     (a.MaxAge + b.MaxAge) / 2,
+	}
+}
+
+func averageEvents(a, b PopulationEvents) PopulationEvents {
+	return PopulationEvents{
+		RabbitBirths:   (a.RabbitBirths + b.RabbitBirths) / 2,
+		FoxBirths:      (a.FoxBirths + b.FoxBirths) / 2,
+		RabbitsEaten:   (a.RabbitsEaten + b.RabbitsEaten) / 2,
+		RabbitsStarved: (a.RabbitsStarved + b.RabbitsStarved) / 2,
+		FoxesStarved:   (a.FoxesStarved + b.FoxesStarved) / 2,
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import "math/rand"
+
+// Synchronous update: every animal decides where to go by looking at the same
+// snapshot of the grid, conflicts over a cell are settled by a fair lottery
+// between the animals that want it, and only then are all moves applied. This
+// removes the bias of the sequential update where animals later in the slice
+// react to the moves of the ones before them.
+
+type moveIntent struct {
+	rabbit *Rabbit
+	fox    *Fox
+	from   Position
+	to     Position
+	action activity
+}
+
+func (w *World) updateSynchronous() {
+	rabbits, foxes := w.ageAll()
+	
+	intents := w.decideIntents(rabbits, foxes)
+	resolveConflicts(intents)
+	w.applyIntents(intents)
+	
+	w.resolveActions(intents)
+}
+
+// ageAll runs the per-tick bookkeeping for every animal and forms the packs.
+// It returns the animals that take part in this tick, leaving out the ones
+// born during it.
+func (w *World) ageAll() ([]*Rabbit, []*Fox) {
+	rabbitCount := len(w.Rabbits)
+	for _, rabbit := range w.Rabbits[:rabbitCount] {
+		w.ageRabbit(rabbit)
+	}
+	
+	w.formPacks()
+	
+	foxCount := len(w.Foxes)
+	for _, fox := range w.Foxes[:foxCount] {
+		w.ageFox(fox)
+	}
+	
+	return w.Rabbits[:rabbitCount], w.Foxes[:foxCount]
+}
+
+// decideIntents lets every animal pick a move on the current, unchanged grid.
+func (w *World) decideIntents(rabbits []*Rabbit, foxes []*Fox) []moveIntent {
+	intents := make([]moveIntent, 0, len(rabbits)+len(foxes))
+	
+	for _, rabbit := range rabbits {
+		intents = append(intents, w.rabbitIntent(rabbit, sharedRand))
+	}
+	
+	for _, fox := range foxes {
+		intents = append(intents, w.foxIntent(fox, sharedRand))
+	}
+	
+	return intents
+}
+
+func (w *World) rabbitIntent(rabbit *Rabbit, rng *rand.Rand) moveIntent {
+	intent := moveIntent{rabbit: rabbit, from: rabbit.Animal.Position, to: rabbit.Animal.Position}
+	if rng.Float64() < w.Params.RabbitMoveChance {
+		intent.to = w.chooseRabbitMove(rabbit, rng)
+		intent.action = activityMove
+	}
+	return intent
+}
+
+func (w *World) foxIntent(fox *Fox, rng *rand.Rand) moveIntent {
+	intent := moveIntent{fox: fox, from: fox.Animal.Position, to: fox.Animal.Position}
+	if rng.Float64() < w.Params.FoxMoveChance {
+		if w.smartHunting {
+			intent.to, intent.action = w.chooseFoxSmartMove(fox, rng)
+		} else {
+			intent.to, intent.action = w.chooseFoxHuntingMove(fox, rng)
+		}
+	}
+	return intent
+}
+
+// resolveConflicts picks one winner uniformly at random for each cell claimed
+// by several animals; the losers stay where they are. Cells are visited in
+// intent order so the result depends only on the random source.
+func resolveConflicts(intents []moveIntent) {
+	claims := make(map[Position][]int)
+	order := make([]Position, 0)
+	for i, intent := range intents {
+		if intent.to == intent.from {
+			continue
+		}
+		if _, seen := claims[intent.to]; !seen {
+			order = append(order, intent.to)
+		}
+		claims[intent.to] = append(claims[intent.to], i)
+	}
+	
+	for _, cell := range order {
+		claimants := claims[cell]
+		if len(claimants) < 2 {
+			continue
+		}
+		
+		winner := claimants[rand.Intn(len(claimants))]
+		for _, i := range claimants {
+			if i != winner {
+				intents[i].to = intents[i].from
+				intents[i].action = activityIdle
+			}
+		}
+	}
+}
+
+// applyIntents moves every animal at once: all movers leave their cells
+// before any of them is placed on its new one.
+func (w *World) applyIntents(intents []moveIntent) {
+	for _, intent := range intents {
+		if intent.to != intent.from {
+			w.Grid[intent.from.X][intent.from.Y] = Empty
+		}
+	}
+	
+	for i := range intents {
+		intent := &intents[i]
+		if intent.rabbit != nil {
+			w.applyRabbitMove(intent.rabbit, intent.to)
+		} else {
+			intent.action = w.applyFoxMove(intent.fox, intent.to, intent.action)
+		}
+	}
+	
+	// Rabbits first so that a fox landing on a rabbit's cell ends up on top
+	for _, intent := range intents {
+		if intent.rabbit != nil {
+			w.Grid[intent.to.X][intent.to.Y] = RabbitType
+		}
+	}
+	for _, intent := range intents {
+		if intent.fox != nil {
+			w.Grid[intent.to.X][intent.to.Y] = FoxType
+		}
+	}
+}
+
+// resolveActions runs everything that happens after the moves: eating,
+// hunting, metabolism, reproduction and deaths.
+func (w *World) resolveActions(intents []moveIntent) {
+	for _, intent := range intents {
+		if intent.rabbit != nil {
+			w.rabbitEatGrass(intent.rabbit)
+		}
+	}
+	
+	for _, intent := range intents {
+		if intent.fox != nil {
+			w.foxHuntRabbit(intent.fox)
+		}
+	}
+	
+	for _, intent := range intents {
+		switch {
+		case intent.rabbit != nil && intent.rabbit.Animal.Energy > 0:
+			w.metabolize(&intent.rabbit.Animal, &w.RabbitParams, intent.action, w.Params.RabbitEnergyLoss)
+			w.depositScent(intent.rabbit.Animal.Position)
+		case intent.fox != nil:
+			w.metabolize(&intent.fox.Animal, &w.FoxParams, intent.action, w.Params.FoxEnergyLoss)
+		}
+	}
+	
+	w.handleRabbitReproduction()
+	for _, intent := range intents {
+		if intent.fox != nil {
+			w.foxReproduction(intent.fox)
+		}
+	}
+	
+	for i := len(w.Rabbits) - 1; i >= 0; i-- {
+		if w.Rabbits[i].Animal.Energy <= 0 {
+			w.Events.RabbitsStarved++
+			w.removeRabbit(i)
+		}
+	}
+	for i := len(w.Foxes) - 1; i >= 0; i-- {
+		if w.Foxes[i].Animal.Energy <= 0 {
+			w.Events.FoxesStarved++
+			w.removeFox(i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Timeline for rewinding. A snapshot is taken every snapshotInterval ticks
+// and the last snapshotCapacity of them are kept. Dragging the slider under
+// the game area or pressing the arrow keys pauses the simulation and shows
+// the world at that tick; resuming continues from there and forgets
+// everything after it.
+
+func (g *Game) recordSnapshot() {
+	if g.headless || g.world.Tick%snapshotInterval != 0 {
+		return
+	}
+	
+	g.timeline.add(g.world.snapshot(g.timeline.last()))
+}
+
+// showSnapshot pauses the simulation and restores the i-th snapshot.
+func (g *Game) showSnapshot(i int) {
+	if i < 0 || i >= g.timeline.len() || i == g.timelineCursor {
+		return
+	}
+	
+	if g.timelineCursor < 0 {
+		g.rewoundFrom = g.world.Tick
+	}
+	g.paused = true
+	g.targetTick = 0
+	g.timelineCursor = i
+	g.world.restore(g.timeline.at(i))
+	g.recordCounter = g.world.Tick % g.sampleInterval
+	g.sampleSelection()
+}
+
+// currentSnapshot returns the index of the snapshot being shown, or the
+// latest one when the simulation is live.
+func (g *Game) currentSnapshot() int {
+	if g.timelineCursor >= 0 {
+		return g.timelineCursor
+	}
+	return g.timeline.len() - 1
+}
+
+func (g *Game) handleTimelineInput() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		g.showSnapshot(g.currentSnapshot() - 1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		g.showSnapshot(g.currentSnapshot() + 1)
+	}
+	
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.scrubbing = false
+		return
+	}
+	
+	x, y := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		g.scrubbing = x >= graphOffsetX && x <= graphOffsetX+graphWidth && y >= timelineY && y <= timelineY+timelineHeight
+	}
+	if !g.scrubbing || g.timeline.len() < 2 {
+		return
+	}
+	
+	fraction := float64(x-graphOffsetX) / float64(graphWidth)
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	g.showSnapshot(int(fraction*float64(g.timeline.len()-1) + 0.5))
+}
+
+func (g *Game) drawTimeline(screen *ebiten.Image) {
+	g.fillRect(screen, graphOffsetX, timelineY, graphWidth, timelineHeight, color.RGBA{50, 50, 50, 255})
+	
+	count := g.timeline.len()
+	if count < 2 {
+		return
+	}
+	
+	current := g.currentSnapshot()
+	filled := graphWidth * current / (count - 1)
+	if filled > 0 {
+		g.fillRect(screen, graphOffsetX, timelineY+2, filled, timelineHeight-4, color.RGBA{90, 90, 140, 255})
+	}
+	
+	markerColor := color.RGBA{200, 200, 255, 255}
+	if g.timelineCursor >= 0 {
+		markerColor = color.RGBA{255, 200, 0, 255}
+	}
+	g.fillRect(screen, graphOffsetX+filled-2, timelineY, 4, timelineHeight, markerColor)
+}
+
+func (g *Game) timelineStatus() string {
+	count := g.timeline.len()
+	if count == 0 {
+		return "Timeline: empty"
+	}
+	
+	first, last := g.timeline.at(0).Tick, g.timeline.last().Tick
+	if g.timelineCursor >= 0 {
+		return fmt.Sprintf("Timeline: REWOUND to tick %d (%d-%d, resume to continue from here)", g.world.Tick, first, last)
+	}
+	return fmt.Sprintf("Timeline: %d snapshots (ticks %d-%d)", count, first, last)
+}
+
+// leaveTimeline is called when a rewound simulation is stepped again. The
+// population history after the shown tick no longer happened and is dropped;
+// the snapshots after it are replaced as new ones are taken.
+func (g *Game) leaveTimeline() {
+	if g.timelineCursor < 0 {
+		return
+	}
+	
+	log.Printf("Resuming from tick %d, later history discarded", g.world.Tick)
+	g.world.recordChange("Rewind", fmt.Sprintf("from tick %d", g.rewoundFrom))
+	g.timelineCursor = -1
+	g.history.truncateAfter(g.world.Tick)
+	if g.worldFrames != nil {
+		g.worldFrames.truncateAfter(g.world.Tick, g.history.len())
+	}
+}
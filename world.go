@@ -3,37 +3,143 @@ package main
 import "math/rand"
 
 type World struct {
+	Width   int
+	Height  int
 	Grid    [][]EntityType
 	Grass   map[Position]*Grass
+	Scent   [][]float64
 	Rabbits []*Rabbit
 	Foxes   []*Fox
+	Packs   [][]*Fox
 	Tick    int
+	
+	Regions       []Region
+	RegionMap     [][]int // Index into Regions for every cell
+	RegionVersion int     // Bumped whenever RegionMap changes
+	
+	HuntAttempts  int
+	HuntSuccesses int
+	Events        PopulationEvents // Births and deaths since the start of the run
+	
+	nextID int
+	
+	Params       SimParams
+	ParamLog     []ParamChange // Parameter changes made while running
+	ChangeLog    []RunChange   // Feature toggles, edits and rewinds made while running
+	RabbitParams SpeciesParams
+	FoxParams    SpeciesParams
+	
+	Seed int64 // Seeds the per-tile random generators of the parallel update
+	
+	regionDistance [][][]int
+	regionCounts   []RegionCount
+	
 	smartHunting bool
+	carryingCapacity bool
+	scentTracking bool
+	packHunting bool
+	herding bool
+	regionsEnabled bool
+	migration bool
+	continuousMetabolism bool
+	gestation bool
+	terrainCover bool
+	synchronous bool
+	workers int
 }
 
-func NewWorld() *World {
+func NewWorld(width, height int) *World {
 	w := &World{
-		Grid:    make([][]EntityType, gridWidth),
+		Width:   width,
+		Height:  height,
+		Grid:    make([][]EntityType, width),
 		Grass:   make(map[Position]*Grass),
+		Scent:   newScentField(width, height),
 		Rabbits: make([]*Rabbit, 0),
 		Foxes:   make([]*Fox, 0),
 		Tick:    0,
 		smartHunting: foxSmartHunting,
+		carryingCapacity: carryingCapacityEnabled,
+		scentTracking: scentTrackingEnabled,
+		packHunting: packHuntingEnabled,
+		herding: herdingEnabled,
+		Regions: defaultRegions(),
+		RegionMap: make([][]int, width),
+		regionsEnabled: regionsEnabled,
+		migration: migrationEnabled,
+		continuousMetabolism: continuousMetabolismEnabled,
+		gestation: gestationEnabled,
+		terrainCover: terrainCoverEnabled,
+		synchronous: synchronousUpdate,
+		workers: parallelWorkers,
+		Params: defaultSimParams(),
+		RabbitParams: defaultRabbitParams(),
+		FoxParams: defaultFoxParams(),
 	}
 	
-	for x := 0; x < gridWidth; x++ {
-		w.Grid[x] = make([]EntityType, gridHeight)
+	for x := 0; x < w.Width; x++ {
+		w.Grid[x] = make([]EntityType, w.Height)
+		w.RegionMap[x] = make([]int, w.Height)
 	}
 	
+	w.generateRegions()
+	w.regionCounts = w.countByRegion()
+	
 	return w
 }
 
 func (w *World) Update() {
+	// Only fox migration and the debug overlay read the counts, both with
+	// regions on; samples count the regions themselves
+	if w.regionsEnabled {
+		w.regionCounts = w.countByRegion()
+	}
+	
+	if w.workers > 1 {
+		w.updateParallel()
+		return
+	}
+	
+	w.updateScent()
 	w.updateGrass()
+	
+	if w.synchronous {
+		w.updateSynchronous()
+		return
+	}
+	
 	w.updateRabbits()
 	w.updateFoxes()
 }
 
+// newID returns an identifier for a newly created animal.
+func (w *World) newID() int {
+	w.nextID++
+	return w.nextID
+}
+
+// RunChange is something done to a running simulation other than tuning a
+// parameter. Kind is FeatureToggle, Edit, Undo, Redo or Rewind.
+type RunChange struct {
+	Tick   int
+	Kind   string
+	Detail string
+}
+
+// recordChange adds an entry to the change log at the current tick.
+func (w *World) recordChange(kind, detail string) {
+	w.ChangeLog = append(w.ChangeLog, RunChange{Tick: w.Tick, Kind: kind, Detail: detail})
+}
+
+// recordToggle logs a feature switched on or off, named as in the metadata.
+func (w *World) recordToggle(feature string, on bool) {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	w.recordChange("FeatureToggle", feature+" "+state)
+}
+
 func (w *World) getAdjacentPositions(pos Position) []Position {
 	adjacent := make([]Position, 0, 8)
 	
@@ -46,7 +152,7 @@ func (w *World) getAdjacentPositions(pos Position) []Position {
 			newX := pos.X + dx
 			newY := pos.Y + dy
 			
-			if newX >= 0 && newX < gridWidth && newY >= 0 && newY < gridHeight {
+			if newX >= 0 && newX < w.Width && newY >= 0 && newY < w.Height {
 				adjacent = append(adjacent, Position{newX, newY})
 			}
 		}
@@ -57,8 +163,8 @@ func (w *World) getAdjacentPositions(pos Position) []Position {
 
 func (w *World) addTestEntities() {
 	for i := 0; i < 30; i++ {
-		x := rand.Intn(gridWidth)
-		y := rand.Intn(gridHeight)
+		x := rand.Intn(w.Width)
+		y := rand.Intn(w.Height)
 		pos := Position{x, y}
 		
 		w.Grass[pos] = &Grass{
@@ -69,16 +175,17 @@ func (w *World) addTestEntities() {
 	}
 	
 	for group := 0; group < 3; group++ {
-		centerX := rand.Intn(gridWidth-10) + 5
-		centerY := rand.Intn(gridHeight-10) + 5
+		centerX := rand.Intn(w.Width-10) + 5
+		centerY := rand.Intn(w.Height-10) + 5
 		
 		for i := 0; i < 3+rand.Intn(2); i++ {
 			x := centerX + rand.Intn(6) - 3
 			y := centerY + rand.Intn(6) - 3
 			
-			if x >= 0 && x < gridWidth && y >= 0 && y < gridHeight && w.Grid[x][y] == Empty {
+			if x >= 0 && x < w.Width && y >= 0 && y < w.Height && w.Grid[x][y] == Empty {
 				rabbit := &Rabbit{
 					Animal: Animal{
+						ID:          w.newID(),
 						Position:    Position{x, y},
 						Energy:      80,
 						ReproduceCD: 0,
@@ -94,16 +201,17 @@ func (w *World) addTestEntities() {
 	}
 	
 	for group := 0; group < 2; group++ {
-		centerX := rand.Intn(gridWidth-6) + 3
-		centerY := rand.Intn(gridHeight-6) + 3
+		centerX := rand.Intn(w.Width-6) + 3
+		centerY := rand.Intn(w.Height-6) + 3
 		
 		for i := 0; i < 2+rand.Intn(2); i++ {
 			x := centerX + rand.Intn(4) - 2
 			y := centerY + rand.Intn(4) - 2
 			
-			if x >= 0 && x < gridWidth && y >= 0 && y < gridHeight && w.Grid[x][y] == Empty {
+			if x >= 0 && x < w.Width && y >= 0 && y < w.Height && w.Grid[x][y] == Empty {
 				fox := &Fox{
 					Animal: Animal{
+						ID:          w.newID(),
 						Position:    Position{x, y},
 						Energy:      80,
 						ReproduceCD: 0,